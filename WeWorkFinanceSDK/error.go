@@ -2,6 +2,8 @@ package WeWorkFinanceSDK
 
 import (
 	"fmt"
+
+	"github.com/fish0607/go-wecom/commons/errhint"
 )
 
 type Error struct {
@@ -10,8 +12,53 @@ type Error struct {
 	Detail  string `json:"-"`
 }
 
+// sentinel errors, works with errors.Is
+var (
+	ErrInvalidParameter   = ErrorOfCode(10000, "")
+	ErrNetwork            = ErrorOfCode(10001, "")
+	ErrDataParse          = ErrorOfCode(10002, "")
+	ErrSystem             = ErrorOfCode(10003, "")
+	ErrEncrypt            = ErrorOfCode(10004, "")
+	ErrInvalidFileID      = ErrorOfCode(10005, "")
+	ErrDecrypt            = ErrorOfCode(10006, "")
+	ErrPrivateKeyNotFound = ErrorOfCode(10007, "")
+	ErrInvalidEncryptKey  = ErrorOfCode(10008, "")
+	ErrInvalidIP          = ErrorOfCode(10009, "")
+	ErrDataExpired        = ErrorOfCode(10010, "")
+	ErrInvalidCertificate = ErrorOfCode(10011, "")
+)
+
+// IsRetryable network, parse or system error - 10001 - 10003
 func (err Error) IsRetryable() bool {
-	return err.Code >= 10001 && err.Code <= 1003
+	return err.Code >= 10001 && err.Code <= 10003
+}
+
+// IsPermissionDenied ip not allowed
+func (err Error) IsPermissionDenied() bool {
+	return err.Code == 10009
+}
+
+// IsNotFound invalid file id or expired data
+func (err Error) IsNotFound() bool {
+	return err.Code == 10005 || err.Code == 10010
+}
+
+// Is match code of target, works with errors.Is
+func (err Error) Is(target error) bool {
+	switch t := target.(type) {
+	case Error:
+		return t.Code == err.Code
+	case *Error:
+		return t != nil && t.Code == err.Code
+	}
+	return false
+}
+
+// Hint of errmsg returned by server
+//
+//	invalid fileid, hint: [1620000000_1_d8c8d], from ip: 1.1.1.1
+func (err Error) Hint() string {
+	return errhint.Hint(err.Message)
 }
 
 func (err Error) Error() string {
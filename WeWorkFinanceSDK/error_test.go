@@ -0,0 +1,26 @@
+package WeWorkFinanceSDK
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	err := ErrorOfCode(10003, "")
+	assert.True(t, err.IsRetryable())
+	assert.False(t, ErrorOfCode(10000, "").IsRetryable())
+	assert.True(t, ErrorOfCode(10009, "").IsPermissionDenied())
+	assert.True(t, ErrorOfCode(10010, "").IsNotFound())
+
+	wrapped := errors.Wrap(Error{Code: 10003, Message: "system error"}, "get chat data")
+	assert.True(t, errors.Is(wrapped, ErrSystem))
+	assert.True(t, errors.Is(wrapped, &ErrSystem))
+	assert.False(t, errors.Is(wrapped, ErrNetwork))
+
+	err = Error{Code: 10005, Message: "invalid fileid, hint: [1620000000_1_d8c8d], from ip: 1.1.1.1"}
+	assert.Equal(t, "1620000000_1_d8c8d", err.Hint())
+	assert.Equal(t, "", ErrInvalidFileID.Hint())
+	assert.NoError(t, Error{}.AsError())
+}
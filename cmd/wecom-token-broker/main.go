@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	dotenv "github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+
+	"github.com/fish0607/go-wecom/wecom"
+	"github.com/fish0607/go-wecom/wecom/tokenbroker"
+)
+
+var (
+	addr     = ":8080"
+	confFile = ""
+	envFile  = ""
+)
+
+func main() {
+	flag.StringVar(&addr, "addr", addr, "listen address")
+	flag.StringVar(&confFile, "conf", confFile, "apps conf file - yaml or json, default to load WECOM_ env")
+	flag.StringVar(&envFile, "env-file", envFile, "load env from file")
+	flag.Parse()
+	if envFile == "" {
+		envFile = os.Getenv("ENV_FILE")
+	}
+	if envFile == "" {
+		envFile = ".env"
+	}
+
+	logrus.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+	if err := dotenv.Load(strings.Split(envFile, ",")...); err != nil {
+		logrus.WithError(err).WithField("env_file", envFile).Warn("load env failed")
+	}
+
+	tokens := mustLoadTokens()
+	confs := mustLoadConfs()
+
+	refresher := &wecom.TokenRefresher{
+		Cache: &wecom.TokenCache{Store: &wecom.SyncMapStore{}, Logger: wecom.LogrusLogger{}},
+		OnError: func(token *wecom.GenericToken, err error) {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"type":     token.Type,
+				"owner_id": token.OwnerID,
+			}).Warn("refresh token failed")
+		},
+	}
+	server := &tokenbroker.Server{Tokens: tokens}
+	for name, conf := range confs {
+		conf.TokenProvider = refresher
+		conf.Logger = wecom.LogrusLogger{}
+		server.Clients = append(server.Clients, wecom.NewClient(conf))
+		logrus.WithFields(logrus.Fields{
+			"app":     name,
+			"corp_id": conf.CorpID,
+		}).Info("serve app")
+	}
+	refresher.Start()
+	defer refresher.Stop()
+
+	logrus.WithField("addr", addr).Info("serving")
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := srv.ListenAndServe(); err != nil {
+		logrus.WithError(err).Fatal("serve")
+	}
+}
+
+// mustLoadTokens bearer tokens of callers from WECOM_BROKER_TOKENS or WECOM_BROKER_TOKENS_FILE, one per line or comma separated
+func mustLoadTokens() []string {
+	v := os.Getenv("WECOM_BROKER_TOKENS")
+	if fn := os.Getenv("WECOM_BROKER_TOKENS_FILE"); v == "" && fn != "" {
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			logrus.WithError(err).Fatal("read broker tokens")
+		}
+		v = string(data)
+	}
+	var tokens []string
+	for _, t := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	if len(tokens) == 0 {
+		logrus.Fatal("missing WECOM_BROKER_TOKENS")
+	}
+	return tokens
+}
+
+func mustLoadConfs() map[string]wecom.Conf {
+	if confFile == "" {
+		conf, err := wecom.ConfFromEnv()
+		if err != nil {
+			logrus.WithError(err).Fatal("load conf from env")
+		}
+		return map[string]wecom.Conf{"default": conf}
+	}
+	f, err := wecom.LoadConfFile(confFile)
+	if err != nil {
+		logrus.WithError(err).Fatal("load conf file")
+	}
+	confs, err := f.Confs()
+	if err != nil {
+		logrus.WithError(err).Fatal("load conf file")
+	}
+	return confs
+}
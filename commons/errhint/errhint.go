@@ -0,0 +1,27 @@
+// Package errhint parse the errmsg returned by wecom servers
+//
+//	invalid access_token, hint: [1620000000_1_d8c8d], from ip: 1.1.1.1, more info at https://open.work.weixin.qq.com/devtool/query?e=40014
+package errhint
+
+import "strings"
+
+// Message of errmsg without hint
+func Message(msg string) string {
+	if i := strings.Index(msg, ", hint: "); i >= 0 {
+		return msg[:i]
+	}
+	return msg
+}
+
+// Hint of errmsg, used to query error detail at https://open.work.weixin.qq.com/devtool/query
+func Hint(msg string) string {
+	i := strings.Index(msg, "hint: [")
+	if i < 0 {
+		return ""
+	}
+	msg = msg[i+len("hint: ["):]
+	if i = strings.IndexByte(msg, ']'); i >= 0 {
+		return msg[:i]
+	}
+	return ""
+}
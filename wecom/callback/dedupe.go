@@ -0,0 +1,124 @@
+package callback
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DedupeStore record the handled events, so the retried or replayed event is handled at most once
+type DedupeStore interface {
+	// Add the key in processing state for ttl, or return the state of the key already added and not expired
+	Add(ctx context.Context, key string, ttl time.Duration) (DedupeState, error)
+	// Done mark the event of key handled, keep the key for ttl
+	Done(ctx context.Context, key string, ttl time.Duration) error
+	// Remove the key, the event failed to handle is handled again by the retry of wecom
+	Remove(ctx context.Context, key string) error
+}
+
+// DedupeState state of the key in DedupeStore
+type DedupeState int
+
+const (
+	// DedupeAdded the key is added, handle the event
+	DedupeAdded DedupeState = iota
+	// DedupeProcessing the event is being handled by another request
+	DedupeProcessing
+	// DedupeDone the event is handled
+	DedupeDone
+)
+
+// DedupeKey of the event - MsgId for messages, event type, CreateTime, ChangeType and digest of the ids for events,
+// the digest covers the whole decrypted xml, which is the same for retries.
+func DedupeKey(e *Event) string {
+	c := e.Common
+	if c.IsMessage() && c.MsgID != 0 {
+		return strings.Join([]string{"msg", e.ReceiverID, strconv.FormatInt(c.MsgID, 10)}, "/")
+	}
+	sum := sha256.Sum256(e.Data)
+	return strings.Join([]string{
+		"event",
+		e.ReceiverID,
+		c.GetEventType(),
+		c.ChangeType,
+		strconv.FormatInt(c.GetTimestamp(), 10),
+		hex.EncodeToString(sum[:16]),
+	}, "/")
+}
+
+// MemoryDedupeStore DedupeStore in memory, the least recently used keys are dropped when full
+type MemoryDedupeStore struct {
+	Size int // max keys, default 10000
+
+	mu    sync.Mutex
+	keys  map[string]*list.Element
+	order *list.List // front is the most recently used
+}
+
+type dedupeEntry struct {
+	key       string
+	done      bool
+	expiresAt time.Time
+}
+
+// Add implements DedupeStore
+func (s *MemoryDedupeStore) Add(_ context.Context, key string, ttl time.Duration) (DedupeState, error) {
+	now := timeNow()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys == nil {
+		s.keys = make(map[string]*list.Element)
+		s.order = list.New()
+	}
+	if el, ok := s.keys[key]; ok {
+		if e := el.Value.(*dedupeEntry); now.Before(e.expiresAt) {
+			s.order.MoveToFront(el)
+			if e.done {
+				return DedupeDone, nil
+			}
+			return DedupeProcessing, nil
+		}
+		s.order.Remove(el)
+	}
+	s.keys[key] = s.order.PushFront(&dedupeEntry{key: key, expiresAt: now.Add(ttl)})
+
+	size := s.Size
+	if size <= 0 {
+		size = 10000
+	}
+	for s.order.Len() > size {
+		el := s.order.Back()
+		s.order.Remove(el)
+		delete(s.keys, el.Value.(*dedupeEntry).key)
+	}
+	return DedupeAdded, nil
+}
+
+// Done implements DedupeStore
+func (s *MemoryDedupeStore) Done(_ context.Context, key string, ttl time.Duration) error {
+	now := timeNow()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.keys[key]; ok {
+		e := el.Value.(*dedupeEntry)
+		e.done, e.expiresAt = true, now.Add(ttl)
+		s.order.MoveToFront(el)
+	}
+	return nil
+}
+
+// Remove implements DedupeStore
+func (s *MemoryDedupeStore) Remove(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.keys[key]; ok {
+		s.order.Remove(el)
+		delete(s.keys, key)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package callback
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryDedupeStore(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	ctx := context.Background()
+	s := &MemoryDedupeStore{Size: 2}
+	add := func(key string) DedupeState {
+		state, err := s.Add(ctx, key, time.Minute)
+		assert.NoError(t, err)
+		return state
+	}
+	assert.Equal(t, DedupeAdded, add("a"))
+	assert.Equal(t, DedupeProcessing, add("a"))
+	assert.NoError(t, s.Done(ctx, "a", time.Hour))
+	assert.Equal(t, DedupeDone, add("a"))
+	assert.NoError(t, s.Remove(ctx, "a"))
+	assert.Equal(t, DedupeAdded, add("a"))
+
+	// expired
+	now = now.Add(time.Minute)
+	assert.Equal(t, DedupeAdded, add("a"))
+	assert.Equal(t, DedupeProcessing, add("a"))
+
+	// least recently used is dropped
+	assert.Equal(t, DedupeAdded, add("b"))
+	assert.Equal(t, DedupeProcessing, add("a"))
+	assert.Equal(t, DedupeAdded, add("c"))
+	assert.Equal(t, DedupeProcessing, add("a"))
+	assert.Equal(t, DedupeAdded, add("b"))
+}
+
+func TestDedupeKey(t *testing.T) {
+	msg := loadEvent(t, "corp", []byte(`<xml><ToUserName>corp</ToUserName><MsgType>text</MsgType><CreateTime>1348831860</CreateTime><MsgId>1234</MsgId></xml>`))
+	assert.Equal(t, "msg/corp/1234", DedupeKey(msg))
+
+	a := loadTestEvent(t, "ChangeContactCreateUser.xml")
+	b := loadTestEvent(t, "ChangeContactCreateUser.xml")
+	assert.Equal(t, DedupeKey(a), DedupeKey(b))
+	assert.Contains(t, DedupeKey(a), "event//change_contact/create_user/1403610513/")
+
+	// other user created at the same time
+	c := loadEvent(t, "", []byte(`<xml><InfoType>change_contact</InfoType><ChangeType>create_user</ChangeType><TimeStamp>1403610513</TimeStamp><UserID>lisi</UserID></xml>`))
+	assert.NotEqual(t, DedupeKey(a), DedupeKey(c))
+}
@@ -0,0 +1,336 @@
+// Package callback serve the callback url of self-built apps and provider/suite callbacks - verify the signature,
+// decrypt the push event, resolve the typed model by wecom.NewEventModel and respond success or an encrypted reply.
+//
+//	http.Handle("/wecom/callback", &callback.Handler{
+//		Crypto: &wwcrypt.Crypto{Token: "", EncodingAESKey: "", ReceiveID: "ww0000000000000000"},
+//		Handle: func(ctx context.Context, e *callback.Event) (interface{}, error) {
+//			log.Println(e.Common.GetEventType())
+//			return nil, nil
+//		},
+//	})
+package callback
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fish0607/go-wecom/wecom"
+	"github.com/fish0607/go-wecom/wwcrypt"
+	"github.com/pkg/errors"
+)
+
+// timeNow is time.Now but pulled out as a variable for tests.
+var timeNow = time.Now
+
+// MaxBodySize max size of the callback request body
+const MaxBodySize = 4 << 20
+
+// Event decrypted push event
+type Event struct {
+	Params     wecom.PushRequestParams
+	ReceiverID string                 // CorpID of self-built app, SuiteID or CorpID of the auth corp for provider callbacks
+	Data       []byte                 // decrypted xml
+	Common     *wecom.CommonPushEvent // common fields
+	Model      wecom.EventModel       // typed model, nil if not registered
+}
+
+// HandlerFunc handle the push event, return nil to respond success, or the passive reply - e.g. wecom.NewTextReply,
+// which is marshalled as xml and encrypted - []byte is used as xml directly.
+type HandlerFunc func(ctx context.Context, e *Event) (reply interface{}, err error)
+
+// Handler http.Handler of callback url, GET verify the url, POST receive the push events
+type Handler struct {
+	// Token and EncodingAESKey of the callback, ReceiveID to check the receiver - empty to accept any,
+	// e.g. the data callback of suite which receive events of all auth corps
+	Crypto *wwcrypt.Crypto
+	Handle HandlerFunc  // nil to respond success, e.g. only save the suite tickets
+	Logger wecom.Logger // log rejected requests and handle errors - default wecom.DefaultLogger
+
+	MaxAge        time.Duration // reject requests whose timestamp is older or newer than MaxAge, default 5m, negative to disable
+	Dedupe        DedupeStore   // skip the handled events, retried or replayed, by DedupeKey - e.g. MemoryDedupeStore
+	DedupeTTL     time.Duration // keep the keys for this duration, should be longer than MaxAge, default 1h
+	ProcessingTTL time.Duration // keep the key of the event being handled, retries get 503 meanwhile, default 1m
+
+	SuiteTickets wecom.SuiteTicketStore // save the suite_ticket pushed to the instruction callback, used as Conf.SuiteTicketStore
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	params := wecom.PushRequestParams{
+		MessageSignature: q.Get("msg_signature"),
+		Timestamp:        q.Get("timestamp"),
+		Nonce:            q.Get("nonce"),
+		EchoString:       q.Get("echostr"),
+	}
+	switch r.Method {
+	case http.MethodGet:
+		h.serveVerify(w, params)
+	case http.MethodPost:
+		h.serveEvent(w, r, params)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// serveVerify respond the decrypted echostr to verify the callback url
+func (h *Handler) serveVerify(w http.ResponseWriter, params wecom.PushRequestParams) {
+	content, err := h.decrypt(params, params.EchoString)
+	if err != nil {
+		h.logger().Warn("verify callback url failed", "error", err)
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write(content.Content)
+}
+
+func (h *Handler) serveEvent(w http.ResponseWriter, r *http.Request, params wecom.PushRequestParams) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	e, err := h.decryptEvent(params, body)
+	if err != nil {
+		h.logger().Warn("decrypt push event failed", "error", err)
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if err = h.saveSuiteTicket(e); err != nil {
+		h.logger().Error("save suite ticket failed", "error", err)
+		http.Error(w, "handle failed", http.StatusInternalServerError)
+		return
+	}
+	reply, err := h.handle(r.Context(), e)
+	if errors.Is(err, errProcessing) {
+		// wecom retries later, the event is handled again if the first delivery failed
+		h.logger().Debug("push event is processing", "key", DedupeKey(e))
+		http.Error(w, "processing", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		// wecom retries when not respond success
+		h.logger().Error("handle push event failed", "event", e.Common.GetEventType(), "change", e.Common.ChangeType, "error", err)
+		http.Error(w, "handle failed", http.StatusInternalServerError)
+		return
+	}
+	if reply == nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, "success")
+		return
+	}
+	out, err := h.encryptReply(e.ReceiverID, reply)
+	if err != nil {
+		h.logger().Error("encrypt reply failed", "event", e.Common.GetEventType(), "error", err)
+		http.Error(w, "handle failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	_, _ = w.Write(out)
+}
+
+// saveSuiteTicket save the ticket of suite_ticket event before handle
+func (h *Handler) saveSuiteTicket(e *Event) error {
+	ev, ok := e.Model.(*wecom.SuiteTicketPushEvent)
+	if !ok || h.SuiteTickets == nil {
+		return nil
+	}
+	return h.SuiteTickets.SetSuiteTicket(ev.SuiteID, ev.SuiteTicket, ev.Timestamp)
+}
+
+// errProcessing the event is being handled by another request
+var errProcessing = errors.New("processing")
+
+// handle the event at most once if Dedupe is set. The key is added in processing state, duplicates arriving
+// meanwhile get errProcessing so wecom retries them, the key is removed when failed so the retry is handled.
+func (h *Handler) handle(ctx context.Context, e *Event) (interface{}, error) {
+	if h.Handle == nil {
+		return nil, nil
+	}
+	if h.Dedupe == nil {
+		return h.Handle(ctx, e)
+	}
+	ttl, processingTTL := h.DedupeTTL, h.ProcessingTTL
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	if processingTTL <= 0 {
+		processingTTL = time.Minute
+	}
+	key := DedupeKey(e)
+	state, err := h.Dedupe.Add(ctx, key, processingTTL)
+	if err != nil {
+		return nil, errors.Wrap(err, "dedupe")
+	}
+	switch state {
+	case DedupeProcessing:
+		return nil, errProcessing
+	case DedupeDone:
+		h.logger().Debug("skip duplicated push event", "key", key)
+		return nil, nil
+	}
+	reply, err := h.Handle(ctx, e)
+	if err != nil {
+		if rerr := h.Dedupe.Remove(ctx, key); rerr != nil {
+			h.logger().Warn("remove dedupe key failed", "key", key, "error", rerr)
+		}
+		return nil, err
+	}
+	if derr := h.Dedupe.Done(ctx, key, ttl); derr != nil {
+		h.logger().Warn("mark dedupe key done failed", "key", key, "error", derr)
+	}
+	return reply, nil
+}
+
+// checkTimestamp reject the request out of MaxAge, so captured requests can not be replayed later
+func (h *Handler) checkTimestamp(timestamp string) error {
+	maxAge := h.MaxAge
+	if maxAge < 0 {
+		return nil
+	} else if maxAge == 0 {
+		maxAge = 5 * time.Minute
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.Errorf("invalid timestamp %q", timestamp)
+	}
+	d := timeNow().Sub(time.Unix(ts, 0))
+	if d > maxAge || d < -maxAge {
+		return errors.Errorf("timestamp %v out of %v", timestamp, maxAge)
+	}
+	return nil
+}
+
+// decryptEvent decrypt the body of wecom.EncryptPushEvent and unmarshal the event
+func (h *Handler) decryptEvent(params wecom.PushRequestParams, body []byte) (*Event, error) {
+	enc := wecom.EncryptPushEvent{}
+	if err := xml.Unmarshal(body, &enc); err != nil {
+		return nil, errors.Wrap(err, "unmarshal encrypted event")
+	}
+	content, err := h.decrypt(params, enc.Encrypt)
+	if err != nil {
+		return nil, err
+	}
+	e := &Event{Params: params, ReceiverID: content.ReceiverID, Data: content.Content}
+	if e.Common, err = wecom.UnmarshalCommonEvent(e.Data); err != nil {
+		return nil, err
+	}
+	if model := wecom.NewEventModel(e.Common.GetEventType(), e.Common.ChangeType); model != nil {
+		if err = xml.Unmarshal(e.Data, model); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal event %v", e.Common.GetEventType())
+		}
+		e.Model = model
+	}
+	return e, nil
+}
+
+// decrypt the encrypted content after the signature is verified
+func (h *Handler) decrypt(params wecom.PushRequestParams, encrypted string) (*wwcrypt.ReceiveContent, error) {
+	if encrypted == "" {
+		return nil, errors.New("missing encrypted content")
+	}
+	c := h.crypto("")
+	sig := c.Signature(params.Timestamp, params.Nonce, encrypted)
+	if subtle.ConstantTimeCompare([]byte(sig), []byte(params.MessageSignature)) != 1 {
+		return nil, errors.New("invalid msg_signature")
+	}
+	if err := h.checkTimestamp(params.Timestamp); err != nil {
+		return nil, err
+	}
+	enc, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode encrypted content")
+	}
+	dec, err := c.Decrypt(enc)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt")
+	}
+	content := &wwcrypt.ReceiveContent{}
+	if err = content.UnmarshalBinary(dec); err != nil {
+		return nil, err
+	}
+	if h.Crypto.ReceiveID != "" && !content.VerifyReceiverID(h.Crypto.ReceiveID) {
+		return nil, errors.Errorf("unexpected receiver id %q", content.ReceiverID)
+	}
+	return content, nil
+}
+
+// EncryptedReply passive reply of the push event
+type EncryptedReply struct {
+	XMLName      xml.Name `xml:"xml"`
+	Encrypt      CDATA    `xml:"Encrypt"`
+	MsgSignature CDATA    `xml:"MsgSignature"`
+	TimeStamp    int64    `xml:"TimeStamp"`
+	Nonce        CDATA    `xml:"Nonce"`
+}
+
+// CDATA xml text as CDATA section
+type CDATA = wecom.CDATA
+
+// encryptReply marshal and encrypt the reply for the receiver of the push event
+func (h *Handler) encryptReply(receiverID string, reply interface{}) ([]byte, error) {
+	return EncryptReply(h.crypto(receiverID), reply)
+}
+
+// EncryptReply marshal the reply as xml - e.g. wecom.TextReply, encrypt by c and wrap as EncryptedReply,
+// []byte is used as xml directly. c.ReceiveID is the receiver of the push event, c is not safe for concurrent use.
+func EncryptReply(c *wwcrypt.Crypto, reply interface{}) ([]byte, error) {
+	data, ok := reply.([]byte)
+	if !ok {
+		var err error
+		if data, err = xml.Marshal(reply); err != nil {
+			return nil, errors.Wrap(err, "marshal reply")
+		}
+	}
+	enc, err := c.EncryptMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomNonce()
+	if err != nil {
+		return nil, err
+	}
+	out := EncryptedReply{
+		Encrypt:   CDATA{Value: base64.StdEncoding.EncodeToString(enc)},
+		TimeStamp: timeNow().Unix(),
+		Nonce:     CDATA{Value: nonce},
+	}
+	out.MsgSignature = CDATA{Value: c.Signature(strconv.FormatInt(out.TimeStamp, 10), nonce, out.Encrypt.Value)}
+	return xml.Marshal(out)
+}
+
+// crypto for a single request, the cipher of wwcrypt.Crypto is stateful and can not be shared by requests
+func (h *Handler) crypto(receiverID string) *wwcrypt.Crypto {
+	if receiverID == "" {
+		receiverID = h.Crypto.ReceiveID
+	}
+	return &wwcrypt.Crypto{
+		ReceiveID:      receiverID,
+		Token:          h.Crypto.Token,
+		EncodingAESKey: h.Crypto.EncodingAESKey,
+	}
+}
+
+func (h *Handler) logger() wecom.Logger {
+	if h.Logger == nil {
+		return wecom.DefaultLogger
+	}
+	return h.Logger
+}
+
+func randomNonce() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,272 @@
+package callback
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fish0607/go-wecom/wecom"
+	"github.com/fish0607/go-wecom/wwcrypt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var testCrypto = wwcrypt.Crypto{
+	ReceiveID:      "wx5823bf96d3bd56c7",
+	Token:          "1372623149",
+	EncodingAESKey: "jWmYm7qr5nMoAUwZRjGtBxmz3KA1tkAj3ykkR6q2B2C",
+}
+
+// encrypt the plain content as wecom does
+func encrypt(t *testing.T, receiverID string, plain []byte) (enc string, q url.Values) {
+	c := testCrypto
+	c.ReceiveID = receiverID
+	data, err := c.EncryptMessage(plain)
+	assert.NoError(t, err)
+	enc = base64.StdEncoding.EncodeToString(data)
+	ts := strconv.FormatInt(timeNow().Unix(), 10)
+	q = url.Values{}
+	q.Set("timestamp", ts)
+	q.Set("nonce", "bqe3yfbqfc7")
+	q.Set("msg_signature", c.Signature(ts, "bqe3yfbqfc7", enc))
+	return
+}
+
+func postEvent(t *testing.T, server *httptest.Server, receiverID string, plain []byte) *http.Response {
+	enc, q := encrypt(t, receiverID, plain)
+	body, err := xml.Marshal(wecom.EncryptPushEvent{ToUserName: receiverID, Encrypt: enc})
+	assert.NoError(t, err)
+	res, err := http.Post(server.URL+"?"+q.Encode(), "application/xml", strings.NewReader(string(body)))
+	assert.NoError(t, err)
+	return res
+}
+
+func readBody(t *testing.T, res *http.Response) string {
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestHandler(t *testing.T) {
+	var last *Event
+	var reply interface{}
+	var handleErr error
+	h := &Handler{
+		Crypto: &wwcrypt.Crypto{Token: testCrypto.Token, EncodingAESKey: testCrypto.EncodingAESKey, ReceiveID: testCrypto.ReceiveID},
+		Handle: func(ctx context.Context, e *Event) (interface{}, error) {
+			last = e
+			return reply, handleErr
+		},
+		Logger: wecom.NopLogger{},
+	}
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	// verify url
+	enc, q := encrypt(t, testCrypto.ReceiveID, []byte("ECHO"))
+	q.Set("echostr", enc)
+	res, err := http.Get(server.URL + "?" + q.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "ECHO", readBody(t, res))
+
+	q.Set("msg_signature", "invalid")
+	res, err = http.Get(server.URL + "?" + q.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	_ = readBody(t, res)
+
+	// typed event
+	data, err := os.ReadFile("../testdata/push/ChangeContactCreateUser.xml")
+	assert.NoError(t, err)
+	res = postEvent(t, server, testCrypto.ReceiveID, data)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "success", readBody(t, res))
+	if assert.NotNil(t, last) {
+		assert.Equal(t, testCrypto.ReceiveID, last.ReceiverID)
+		assert.Equal(t, "create_user", last.Common.ChangeType)
+		assert.Equal(t, "zhangsan", last.Model.(*wecom.ChangeContactCreateUserPushEvent).UserID)
+	}
+
+	// unknown event is dispatched without model
+	last = nil
+	res = postEvent(t, server, testCrypto.ReceiveID, []byte(`<xml><InfoType>unknown</InfoType></xml>`))
+	assert.Equal(t, "success", readBody(t, res))
+	if assert.NotNil(t, last) {
+		assert.Nil(t, last.Model)
+		assert.Equal(t, "unknown", last.Common.GetEventType())
+	}
+
+	// receiver mismatch
+	last = nil
+	res = postEvent(t, server, "other", data)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	_ = readBody(t, res)
+	assert.Nil(t, last)
+
+	// encrypted reply
+	reply = []byte(`<xml><MsgType>text</MsgType></xml>`)
+	res = postEvent(t, server, testCrypto.ReceiveID, data)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	out := EncryptedReply{}
+	assert.NoError(t, xml.Unmarshal([]byte(readBody(t, res)), &out))
+	c := testCrypto
+	dec, err := c.Verify(out.MsgSignature.Value, strconv.FormatInt(out.TimeStamp, 10), out.Nonce.Value, out.Encrypt.Value)
+	assert.NoError(t, err)
+	assert.Equal(t, `<xml><MsgType>text</MsgType></xml>`, string(dec))
+
+	// handle error - wecom will retry
+	reply, handleErr = nil, errors.New("failed")
+	res = postEvent(t, server, testCrypto.ReceiveID, data)
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+	_ = readBody(t, res)
+}
+
+func TestHandlerAnyReceiver(t *testing.T) {
+	var last *Event
+	h := &Handler{
+		// suite data callback - events of all auth corps
+		Crypto: &wwcrypt.Crypto{Token: testCrypto.Token, EncodingAESKey: testCrypto.EncodingAESKey},
+		Handle: func(ctx context.Context, e *Event) (interface{}, error) {
+			last = e
+			return []byte(`<xml/>`), nil
+		},
+	}
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	res := postEvent(t, server, "AuthCorpID", []byte(`<xml><InfoType>cancel_auth</InfoType><AuthCorpId>AuthCorpID</AuthCorpId></xml>`))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	out := EncryptedReply{}
+	assert.NoError(t, xml.Unmarshal([]byte(readBody(t, res)), &out))
+	assert.Equal(t, "AuthCorpID", last.ReceiverID)
+	assert.Equal(t, "AuthCorpID", last.Model.(*wecom.CancelAuthPushEvent).AuthCorpID)
+
+	// reply is encrypted for the receiver of the event
+	c := testCrypto
+	c.ReceiveID = "AuthCorpID"
+	dec, err := c.Verify(out.MsgSignature.Value, strconv.FormatInt(out.TimeStamp, 10), out.Nonce.Value, out.Encrypt.Value)
+	assert.NoError(t, err)
+	assert.Equal(t, `<xml/>`, string(dec))
+}
+
+func TestHandlerReply(t *testing.T) {
+	h := &Handler{
+		Crypto: &wwcrypt.Crypto{Token: testCrypto.Token, EncodingAESKey: testCrypto.EncodingAESKey, ReceiveID: testCrypto.ReceiveID},
+		Handle: func(ctx context.Context, e *Event) (interface{}, error) {
+			return wecom.NewTextReply(e.Common, "hello"), nil
+		},
+	}
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	res := postEvent(t, server, testCrypto.ReceiveID, []byte(`<xml><ToUserName>wx5823bf96d3bd56c7</ToUserName><FromUserName>zhangsan</FromUserName>`+
+		`<CreateTime>1348831860</CreateTime><MsgType>text</MsgType><Content>hi</Content><MsgId>1</MsgId><AgentID>1</AgentID></xml>`))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	out := EncryptedReply{}
+	assert.NoError(t, xml.Unmarshal([]byte(readBody(t, res)), &out))
+	c := testCrypto
+	dec, err := c.Verify(out.MsgSignature.Value, strconv.FormatInt(out.TimeStamp, 10), out.Nonce.Value, out.Encrypt.Value)
+	assert.NoError(t, err)
+	reply := wecom.TextReply{}
+	assert.NoError(t, xml.Unmarshal(dec, &reply))
+	assert.Equal(t, "zhangsan", reply.ToUsername.Value)
+	assert.Equal(t, testCrypto.ReceiveID, reply.FromUsername.Value)
+	assert.Equal(t, "text", reply.MsgType.Value)
+	assert.Equal(t, "hello", reply.Content.Value)
+}
+
+func TestHandlerReplay(t *testing.T) {
+	var handled int
+	var handleErr error
+	block := make(chan struct{})
+	started := make(chan struct{}, 1)
+	h := &Handler{
+		Crypto: &wwcrypt.Crypto{Token: testCrypto.Token, EncodingAESKey: testCrypto.EncodingAESKey, ReceiveID: testCrypto.ReceiveID},
+		Handle: func(ctx context.Context, e *Event) (interface{}, error) {
+			handled++
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-block
+			return nil, handleErr
+		},
+		Logger: wecom.NopLogger{},
+		Dedupe: &MemoryDedupeStore{},
+	}
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	data, err := os.ReadFile("../testdata/push/ChangeContactCreateUser.xml")
+	assert.NoError(t, err)
+	enc, q := encrypt(t, testCrypto.ReceiveID, data)
+	body, err := xml.Marshal(wecom.EncryptPushEvent{ToUserName: testCrypto.ReceiveID, Encrypt: enc})
+	assert.NoError(t, err)
+	post := func() int {
+		res, err := http.Post(server.URL+"?"+q.Encode(), "application/xml", strings.NewReader(string(body)))
+		assert.NoError(t, err)
+		_ = readBody(t, res)
+		return res.StatusCode
+	}
+
+	// retry arriving while the event is still handled is not acknowledged
+	handleErr = errors.New("failed")
+	first := make(chan int)
+	go func() { first <- post() }()
+	<-started
+	assert.Equal(t, http.StatusServiceUnavailable, post())
+	close(block)
+	// failed event is handled again by the retry
+	assert.Equal(t, http.StatusInternalServerError, <-first)
+	handleErr = nil
+	assert.Equal(t, http.StatusOK, post())
+	// retried or replayed
+	assert.Equal(t, http.StatusOK, post())
+	assert.Equal(t, 2, handled)
+
+	// captured request replayed later
+	now := time.Now()
+	timeNow = func() time.Time { return now.Add(6 * time.Minute) }
+	defer func() { timeNow = time.Now }()
+	h.Dedupe = nil
+	assert.Equal(t, http.StatusBadRequest, post())
+	h.MaxAge = 10 * time.Minute
+	assert.Equal(t, http.StatusOK, post())
+	h.MaxAge = -1
+	timeNow = func() time.Time { return now.Add(time.Hour) }
+	assert.Equal(t, http.StatusOK, post())
+	assert.Equal(t, 4, handled)
+}
+
+func TestHandlerSuiteTickets(t *testing.T) {
+	tickets := &wecom.TokenSuiteTicketStore{Store: &wecom.SyncMapStore{}}
+	h := &Handler{
+		// instruction callback
+		Crypto:       &wwcrypt.Crypto{Token: testCrypto.Token, EncodingAESKey: testCrypto.EncodingAESKey, ReceiveID: "ww4asffe99e54c0f4c"},
+		SuiteTickets: tickets,
+	}
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	data, err := os.ReadFile("../testdata/push/SuiteTicket.xml")
+	assert.NoError(t, err)
+	res := postEvent(t, server, "ww4asffe99e54c0f4c", bytes.Replace(data, []byte("1403610513"), []byte(strconv.FormatInt(time.Now().Unix(), 10)), 1))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "success", readBody(t, res))
+
+	ticket, err := tickets.SuiteTicket("ww4asffe99e54c0f4c")
+	assert.NoError(t, err)
+	assert.Equal(t, "asdfasfdasdfasdf", ticket)
+}
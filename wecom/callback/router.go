@@ -0,0 +1,205 @@
+package callback
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/fish0607/go-wecom/wecom"
+	"github.com/pkg/errors"
+)
+
+// Middleware wrap the HandlerFunc of Router, e.g. Logging, Recovery, ResolveClient
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Router dispatch push events to the handlers of the model type or event type, use ServeEvent as Handler.Handle.
+//
+//	r := &callback.Router{}
+//	r.Use(callback.Recovery(nil), callback.Logging(nil))
+//	callback.On(r, func(ctx context.Context, ev *wecom.ChangeExternalContactAddExternalContactPushEvent, common *wecom.CommonPushEvent) error {
+//		return nil
+//	})
+//	r.HandleEvent("change_contact", "create_user", handler)
+//	http.Handle("/wecom/callback", &callback.Handler{Crypto: crypto, Handle: r.ServeEvent})
+//
+// Handlers are matched in order - model type, event type and change type, event type of any change type,
+// then Unknown for events without registered model and Fallback. Events without handler respond success.
+type Router struct {
+	Unknown  HandlerFunc // events without model registered by wecom.RegisterEventModel, default Fallback
+	Fallback HandlerFunc // events not matched by any handler
+
+	mu          sync.RWMutex
+	middlewares []Middleware
+	types       map[reflect.Type]HandlerFunc
+	events      map[routeKey]HandlerFunc
+}
+
+type routeKey struct {
+	event  string
+	change string
+}
+
+// Use middlewares, the first one is the outermost
+func (r *Router) Use(mw ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append(r.middlewares, mw...)
+}
+
+// HandleEvent handle events of eventType - InfoType, Event or MsgType, empty changeType to match any ChangeType
+func (r *Router) HandleEvent(eventType, changeType string, h HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.events == nil {
+		r.events = make(map[routeKey]HandlerFunc)
+	}
+	r.events[routeKey{event: eventType, change: changeType}] = h
+}
+
+func (r *Router) handleType(typ reflect.Type, h HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.types == nil {
+		r.types = make(map[reflect.Type]HandlerFunc)
+	}
+	r.types[typ] = h
+}
+
+// On handle events of model T, e.g. *wecom.SuiteTicketPushEvent
+func On[T wecom.EventModel](r *Router, h func(ctx context.Context, ev T, common *wecom.CommonPushEvent) error) {
+	OnReply(r, func(ctx context.Context, ev T, common *wecom.CommonPushEvent) (interface{}, error) {
+		return nil, h(ctx, ev, common)
+	})
+}
+
+// OnReply handle events of model T with passive reply
+func OnReply[T wecom.EventModel](r *Router, h func(ctx context.Context, ev T, common *wecom.CommonPushEvent) (interface{}, error)) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() == reflect.Interface {
+		panic(fmt.Sprintf("callback: On requires a model type, got interface %v", typ))
+	}
+	if typ.Kind() != reflect.Pointer {
+		// models are unmarshalled as pointer
+		typ = reflect.PointerTo(typ)
+	}
+	r.handleType(typ, func(ctx context.Context, e *Event) (interface{}, error) {
+		ev, ok := e.Model.(T)
+		if !ok {
+			ev = reflect.ValueOf(e.Model).Elem().Interface().(T)
+		}
+		return h(ctx, ev, e.Common)
+	})
+}
+
+// ServeEvent dispatch the event through the middlewares, used as Handler.Handle
+func (r *Router) ServeEvent(ctx context.Context, e *Event) (interface{}, error) {
+	r.mu.RLock()
+	h := r.match(e)
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		h = r.middlewares[i](h)
+	}
+	r.mu.RUnlock()
+	return h(ctx, e)
+}
+
+func (r *Router) match(e *Event) HandlerFunc {
+	if e.Model != nil {
+		if h := r.types[reflect.TypeOf(e.Model)]; h != nil {
+			return h
+		}
+	}
+	typ := e.Common.GetEventType()
+	if h := r.events[routeKey{event: typ, change: e.Common.ChangeType}]; h != nil {
+		return h
+	}
+	if h := r.events[routeKey{event: typ}]; h != nil {
+		return h
+	}
+	if e.Model == nil && r.Unknown != nil {
+		return r.Unknown
+	}
+	if r.Fallback != nil {
+		return r.Fallback
+	}
+	return func(ctx context.Context, e *Event) (interface{}, error) {
+		return nil, nil
+	}
+}
+
+// Logging log every event at debug level and the errors
+func Logging(logger wecom.Logger) Middleware {
+	if logger == nil {
+		logger = wecom.DefaultLogger
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, e *Event) (interface{}, error) {
+			start := time.Now()
+			reply, err := next(ctx, e)
+			kv := []interface{}{
+				"event", e.Common.GetEventType(),
+				"change", e.Common.ChangeType,
+				"receiver", e.ReceiverID,
+				"duration", time.Since(start),
+			}
+			if err != nil {
+				logger.Error("handle push event failed", append(kv, "error", err)...)
+			} else {
+				logger.Debug("handle push event", append(kv, "reply", reply != nil)...)
+			}
+			return reply, err
+		}
+	}
+}
+
+// Recovery recover the panic of handlers as error, so the event is retried by wecom
+func Recovery(logger wecom.Logger) Middleware {
+	if logger == nil {
+		logger = wecom.DefaultLogger
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, e *Event) (reply interface{}, err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					logger.Error("handle push event panic", "event", e.Common.GetEventType(), "panic", v, "stack", string(debug.Stack()))
+					reply, err = nil, errors.Errorf("panic: %v", v)
+				}
+			}()
+			return next(ctx, e)
+		}
+	}
+}
+
+// ResolveClient put the Client of the event in context, get by wecom.FromContext, nil Client is skipped
+func ResolveClient(resolve func(ctx context.Context, e *Event) (*wecom.Client, error)) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, e *Event) (interface{}, error) {
+			client, err := resolve(ctx, e)
+			if err != nil {
+				return nil, errors.Wrap(err, "resolve client")
+			}
+			if client != nil {
+				ctx = wecom.NewContext(ctx, client.WithContext(ctx))
+			}
+			return next(ctx, e)
+		}
+	}
+}
+
+// ResolveAuthCorpClient put the Client of the auth corp in context by wecom.ClientRegistry, the auth corp is
+// AuthCorpId of the event or the receiver of data callbacks, events of the suite - e.g. suite_ticket - are skipped
+func ResolveAuthCorpClient(registry *wecom.ClientRegistry) Middleware {
+	return ResolveClient(func(ctx context.Context, e *Event) (*wecom.Client, error) {
+		common := *e.Common
+		if common.AuthCorpID == "" && e.ReceiverID != registry.Client.Conf.SuiteID {
+			// data callback is encrypted for the auth corp
+			common.AuthCorpID = e.ReceiverID
+		}
+		if common.AuthCorpID == "" {
+			return nil, nil
+		}
+		return registry.ForEvent(&common)
+	})
+}
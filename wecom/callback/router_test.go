@@ -0,0 +1,156 @@
+package callback
+
+import (
+	"context"
+	"encoding/xml"
+	"os"
+	"testing"
+
+	"github.com/fish0607/go-wecom/wecom"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadEvent(t *testing.T, receiverID string, data []byte) *Event {
+	e := &Event{ReceiverID: receiverID, Data: data}
+	var err error
+	e.Common, err = wecom.UnmarshalCommonEvent(data)
+	assert.NoError(t, err)
+	if e.Model = wecom.NewEventModel(e.Common.GetEventType(), e.Common.ChangeType); e.Model != nil {
+		assert.NoError(t, xml.Unmarshal(data, e.Model))
+	}
+	return e
+}
+
+func loadTestEvent(t *testing.T, name string) *Event {
+	data, err := os.ReadFile("../testdata/push/" + name)
+	assert.NoError(t, err)
+	return loadEvent(t, "", data)
+}
+
+func TestRouter(t *testing.T) {
+	var handled []string
+	r := &Router{}
+	On(r, func(ctx context.Context, ev *wecom.ChangeExternalContactAddExternalContactPushEvent, common *wecom.CommonPushEvent) error {
+		handled = append(handled, "add_external_contact:"+ev.ExternalUserID)
+		return nil
+	})
+	// value type is matched by the pointer model
+	OnReply(r, func(ctx context.Context, ev wecom.SuiteTicketPushEvent, common *wecom.CommonPushEvent) (interface{}, error) {
+		handled = append(handled, "suite_ticket:"+ev.SuiteTicket)
+		return []byte("<xml/>"), nil
+	})
+	r.HandleEvent("change_contact", "create_user", func(ctx context.Context, e *Event) (interface{}, error) {
+		handled = append(handled, "create_user")
+		return nil, nil
+	})
+	r.HandleEvent("change_contact", "", func(ctx context.Context, e *Event) (interface{}, error) {
+		handled = append(handled, "change_contact:"+e.Common.ChangeType)
+		return nil, nil
+	})
+	r.Fallback = func(ctx context.Context, e *Event) (interface{}, error) {
+		handled = append(handled, "fallback:"+e.Common.GetEventType())
+		return nil, nil
+	}
+
+	ctx := context.Background()
+	for _, name := range []string{
+		"ChangeExternalContactAddExternalContact.xml",
+		"SuiteTicket.xml",
+		"ChangeContactCreateUser.xml",
+		"ChangeContactDeleteUser.xml",
+		"CancelAuth.xml",
+	} {
+		reply, err := r.ServeEvent(ctx, loadTestEvent(t, name))
+		assert.NoError(t, err)
+		if name == "SuiteTicket.xml" {
+			assert.Equal(t, []byte("<xml/>"), reply)
+		} else {
+			assert.Nil(t, reply)
+		}
+	}
+	unknown := loadEvent(t, "", []byte(`<xml><InfoType>unknown</InfoType></xml>`))
+	_, err := r.ServeEvent(ctx, unknown)
+	assert.NoError(t, err)
+	r.Unknown = func(ctx context.Context, e *Event) (interface{}, error) {
+		handled = append(handled, "unknown:"+e.Common.GetEventType())
+		return nil, nil
+	}
+	_, err = r.ServeEvent(ctx, unknown)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"add_external_contact:woAJ2GCAAAXtWyujaWJHDDGi0mACH71w",
+		"suite_ticket:asdfasfdasdfasdf",
+		"create_user",
+		"change_contact:delete_user",
+		"fallback:cancel_auth",
+		"fallback:unknown",
+		"unknown:unknown",
+	}, handled)
+
+	assert.Panics(t, func() {
+		On(r, func(ctx context.Context, ev wecom.EventModel, common *wecom.CommonPushEvent) error {
+			return nil
+		})
+	})
+}
+
+func TestRouterMiddleware(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, e *Event) (interface{}, error) {
+				order = append(order, name)
+				return next(ctx, e)
+			}
+		}
+	}
+	r := &Router{}
+	r.Use(Recovery(wecom.NopLogger{}), Logging(wecom.NopLogger{}), trace("a"), trace("b"))
+	r.Fallback = func(ctx context.Context, e *Event) (interface{}, error) {
+		order = append(order, "handler")
+		return nil, errors.New("failed")
+	}
+	On(r, func(ctx context.Context, ev *wecom.CancelAuthPushEvent, common *wecom.CommonPushEvent) error {
+		panic("boom")
+	})
+
+	_, err := r.ServeEvent(context.Background(), loadTestEvent(t, "SuiteTicket.xml"))
+	assert.EqualError(t, err, "failed")
+	assert.Equal(t, []string{"a", "b", "handler"}, order)
+
+	_, err = r.ServeEvent(context.Background(), loadTestEvent(t, "CancelAuth.xml"))
+	assert.EqualError(t, err, "panic: boom")
+}
+
+func TestResolveAuthCorpClient(t *testing.T) {
+	registry := &wecom.ClientRegistry{
+		Client: wecom.NewClient(wecom.Conf{SuiteID: "SuiteID", SuiteSecret: "SuiteSecret"}),
+	}
+	var clients []*wecom.Client
+	r := &Router{}
+	r.Use(ResolveAuthCorpClient(registry))
+	r.Fallback = func(ctx context.Context, e *Event) (interface{}, error) {
+		clients = append(clients, wecom.FromContext(ctx))
+		return nil, nil
+	}
+	ctx := context.Background()
+	for _, e := range []*Event{
+		loadEvent(t, "SuiteID", []byte(`<xml><SuiteId>SuiteID</SuiteId><InfoType>cancel_auth</InfoType><AuthCorpId>A</AuthCorpId></xml>`)),
+		loadEvent(t, "SuiteID", []byte(`<xml><SuiteId>SuiteID</SuiteId><InfoType>suite_ticket</InfoType></xml>`)),
+		// data callback
+		loadEvent(t, "B", []byte(`<xml><ToUserName>B</ToUserName><MsgType>event</MsgType><Event>change_contact</Event></xml>`)),
+	} {
+		_, err := r.ServeEvent(ctx, e)
+		assert.NoError(t, err)
+	}
+	if assert.Len(t, clients, 3) {
+		assert.Equal(t, "A", clients[0].Conf.AuthCorpID)
+		assert.Nil(t, clients[1])
+		assert.Equal(t, "B", clients[2].Conf.AuthCorpID)
+	}
+
+	_, err := r.ServeEvent(ctx, loadEvent(t, "Other", []byte(`<xml><SuiteId>Other</SuiteId><InfoType>cancel_auth</InfoType><AuthCorpId>A</AuthCorpId></xml>`)))
+	assert.Error(t, err)
+}
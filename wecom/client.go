@@ -12,6 +12,12 @@ type Client struct {
 	Conf          Conf
 	Request       req.Request
 	TokenProvider TokenProvider
+	RateLimiter   RateLimiter
+	Backoff       *Backoff // retry when frequency limited, nil to fail fast - e.g. a copy of DefaultBackoff
+	Observer      Observer
+	Logger        Logger // nil to use DefaultLogger, set to log api calls at debug level
+
+	secrets *secretCache
 }
 
 // NewClient create a new Client
@@ -24,14 +30,20 @@ func NewClient(conf Conf) *Client {
 			Method:  http.MethodGet,
 			Options: []interface{}{requestMiddleware, req.JSONEncode, req.JSONDecode},
 		},
+		secrets: &secretCache{},
 	}
 	c.Request.Context = NewContext(ctx, c)
 	c.Request.Extension.With(wecomeMiddleware)
 	c.TokenProvider = conf.TokenProvider
+	c.RateLimiter = conf.RateLimiter
+	c.Backoff = conf.Backoff
+	c.Observer = conf.Observer
+	c.Logger = conf.Logger
 
 	if c.TokenProvider == nil {
 		c.TokenProvider = &TokenCache{
-			Store: &SyncMapStore{},
+			Store:  &SyncMapStore{Logger: conf.Logger},
+			Logger: conf.Logger,
 		}
 	}
 	return c
@@ -42,17 +54,48 @@ func (c *Client) With(conf Conf) (neo *Client) {
 	cc := *c
 	neo = &cc
 	neo.Conf = conf
+	neo.secrets = &secretCache{}
 	neo.Request.Context = NewContext(cc.Request.Context, neo)
 	return
 }
 
+// WithContext create new Client which send requests with ctx, ctx can cancel the request or carry deadline and values
+func (c *Client) WithContext(ctx context.Context) (neo *Client) {
+	cc := *c
+	neo = &cc
+	neo.Request.Context = NewContext(ctx, neo)
+	return
+}
+
+// detached Client not canceled with the context of the request, used to fetch tokens which are shared by requests
+// and refreshed in background
+func (c *Client) detached() *Client {
+	ctx := c.Context()
+	if ctx.Done() == nil {
+		return c
+	}
+	return c.WithContext(detachedContext{ctx})
+}
+
+// Context used by requests of this Client
+func (c *Client) Context() context.Context {
+	if c.Request.Context == nil {
+		return context.Background()
+	}
+	return c.Request.Context
+}
+
 // GetToken request an access_token
 func (c *Client) GetToken() (out TokenResponse, err error) {
+	secret, err := c.secret(SecretCorpSecret)
+	if err != nil {
+		return
+	}
 	err = c.Request.With(req.Request{
 		URL: "/cgi-bin/gettoken",
 		Query: map[string]interface{}{
 			"corpid":     c.Conf.CorpID,
-			"corpsecret": c.Conf.CorpSecret,
+			"corpsecret": secret,
 		},
 		Options: []interface{}{WithoutAccessToken},
 	}).Fetch(&out)
@@ -32,50 +32,143 @@ func joinIds(s ...string) string {
 	return o
 }
 
-// JsAPITicket request or return cached JsAPITicket
-func (c *Client) JsAPITicket() (string, error) {
-	// depends on AccessToken
-	t := &GenericToken{
-		OwnerID: c.Conf.CorpID,
-		Type:    TokenTypeJsAPITicket,
-	}
-	if c.Conf.SuiteID != "" && c.Conf.AuthCorpID != "" {
+// tokenOf build the cache key of token typ for current Conf
+func (c *Client) tokenOf(typ TokenType) *GenericToken {
+	t := &GenericToken{Type: typ}
+	switch typ {
+	case TokenTypeJsAPITicket, TokenTypeAgentTicket:
+		// depends on AccessToken
+		t.OwnerID = c.Conf.CorpID
+		if c.Conf.SuiteID != "" && c.Conf.AuthCorpID != "" {
+			t.OwnerID = joinIds(c.Conf.SuiteID, c.Conf.AuthCorpID)
+		}
+		t.Depends = c.tokenOf(TokenTypeAccessToken).Depends
+	case TokenTypeAccessToken:
+		t.OwnerID = c.Conf.CorpID
+		if !c.hasSecret(SecretCorpSecret) && c.Conf.SuiteID != "" && c.Conf.AuthCorpID != "" {
+			return c.tokenOf(TokenTypeAuthCorpAccessToken)
+		}
+		t.Depends = c.secretFingerprint(SecretCorpSecret)
+	case TokenTypeAuthCorpAccessToken, TokenTypeAuthCorpPermanentCode:
+		// depends on Suite
 		t.OwnerID = joinIds(c.Conf.SuiteID, c.Conf.AuthCorpID)
+	case TokenTypeProviderAccessToken:
+		t.OwnerID = c.Conf.CorpID
+		t.Depends = c.secretFingerprint(SecretProviderSecret)
+	case TokenTypeSuiteAccessToken:
+		t.OwnerID = c.Conf.SuiteID
+		t.Depends = c.secretFingerprint(SecretSuiteSecret)
+	case TokenTypeSuiteTicket, TokenTypeSuitePreAuthCode:
+		t.OwnerID = c.Conf.SuiteID
+	}
+	return t
+}
+
+// invalidateToken drop the cached token typ if it still is the rejected secret
+func (c *Client) invalidateToken(typ TokenType, secret string) error {
+	inv, ok := c.TokenProvider.(TokenInvalidator)
+	if !ok {
+		return errors.Errorf("TokenProvider %T can not invalidate token", c.TokenProvider)
+	}
+	t := c.tokenOf(typ)
+	t.Secret = secret
+	return inv.Invalidate(t)
+}
+
+// tokenDependents tokens derived from the token type, invalidated together
+var tokenDependents = map[TokenType][]TokenType{
+	TokenTypeAccessToken:         {TokenTypeJsAPITicket, TokenTypeAgentTicket},
+	TokenTypeAuthCorpAccessToken: {TokenTypeJsAPITicket, TokenTypeAgentTicket},
+	TokenTypeSuiteAccessToken:    {TokenTypeSuitePreAuthCode},
+}
+
+// suiteDependents tokens of every auth corp derived from the SuiteAccessToken, OwnerID prefixed by SuiteID
+var suiteDependents = []TokenType{TokenTypeAuthCorpAccessToken, TokenTypeJsAPITicket, TokenTypeAgentTicket}
+
+// InvalidateToken drop the cached token typ of current Conf and the tokens derived from it,
+// e.g. after the secret is rotated. AccessToken cascade to JsAPITicket and AgentTicket,
+// SuiteAccessToken cascade to AuthCorpAccessToken and tickets of all auth corps of the suite.
+func (c *Client) InvalidateToken(typ TokenType) error {
+	inv, ok := c.TokenProvider.(TokenInvalidator)
+	if !ok {
+		return errors.Errorf("TokenProvider %T can not invalidate token", c.TokenProvider)
 	}
-	return c.TokenProvider.Refresh(t, func() (OpaqueToken, error) {
+	t := c.tokenOf(typ)
+	if err := inv.Invalidate(t); err != nil {
+		return errors.Wrapf(err, "invalidate %v", t.Type)
+	}
+	for _, v := range tokenDependents[t.Type] {
+		if err := c.InvalidateToken(v); err != nil {
+			return err
+		}
+	}
+	if t.Type == TokenTypeSuiteAccessToken && c.Conf.SuiteID != "" {
+		for _, v := range suiteDependents {
+			if err := inv.InvalidatePrefix(v, c.Conf.SuiteID+"."); err != nil {
+				return errors.Wrapf(err, "invalidate %v of suite %v", v, c.Conf.SuiteID)
+			}
+		}
+	}
+	return nil
+}
+
+// TokenOf the cache key of token typ for current Conf, Secret is not loaded
+func (c *Client) TokenOf(typ TokenType) *GenericToken {
+	return c.tokenOf(typ)
+}
+
+// Token request or return cached token typ with expiry, supports AccessToken, AuthCorpAccessToken,
+// ProviderAccessToken, SuiteAccessToken, JsAPITicket and AgentTicket
+func (c *Client) Token(typ TokenType) (*GenericToken, error) {
+	switch typ {
+	case TokenTypeAccessToken:
+		return c.accessToken()
+	case TokenTypeAuthCorpAccessToken:
+		return c.authCorpAccessToken()
+	case TokenTypeProviderAccessToken:
+		return c.providerAccessToken()
+	case TokenTypeSuiteAccessToken:
+		return c.suiteAccessToken()
+	case TokenTypeJsAPITicket:
+		return c.jsAPITicket()
+	case TokenTypeAgentTicket:
+		return c.agentTicket()
+	}
+	return nil, errors.Errorf("unsupported token type %v", typ)
+}
+
+// JsAPITicket request or return cached JsAPITicket
+func (c *Client) JsAPITicket() (string, error) {
+	return secretOf(c.jsAPITicket())
+}
+
+func (c *Client) jsAPITicket() (*GenericToken, error) {
+	return c.refresh(c.tokenOf(TokenTypeJsAPITicket), func(c *Client) (OpaqueToken, error) {
 		return c.GetJsAPITicket()
 	})
 }
 
 // AgentTicket request or return cached AgentTicket
 func (c *Client) AgentTicket() (string, error) {
-	// depends on AccessToken
-	t := &GenericToken{
-		OwnerID: c.Conf.CorpID,
-		Type:    TokenTypeAgentTicket,
-	}
-	if c.Conf.SuiteID != "" && c.Conf.AuthCorpID != "" {
-		t.OwnerID = joinIds(c.Conf.SuiteID, c.Conf.AuthCorpID)
-	}
+	return secretOf(c.agentTicket())
+}
 
-	return c.TokenProvider.Refresh(t, func() (OpaqueToken, error) {
+func (c *Client) agentTicket() (*GenericToken, error) {
+	return c.refresh(c.tokenOf(TokenTypeAgentTicket), func(c *Client) (OpaqueToken, error) {
 		return c.GetAgentTicket()
 	})
 }
 
 // AuthCorpAccessToken request or return cached AuthCorpAccessToken
 func (c *Client) AuthCorpAccessToken() (string, error) {
-	// depends on Suite
-	return c.TokenProvider.Refresh(&GenericToken{
-		OwnerID: joinIds(c.Conf.SuiteID, c.Conf.AuthCorpID),
-		Type:    TokenTypeAuthCorpAccessToken,
-	}, func() (o OpaqueToken, err error) {
+	return secretOf(c.authCorpAccessToken())
+}
+
+func (c *Client) authCorpAccessToken() (*GenericToken, error) {
+	return c.refresh(c.tokenOf(TokenTypeAuthCorpAccessToken), func(c *Client) (o OpaqueToken, err error) {
 		code := c.Conf.AuthCorpPermanentCode
 		if code == "" {
-			code, err = c.TokenProvider.Refresh(&GenericToken{
-				OwnerID: joinIds(c.Conf.SuiteID, c.Conf.AuthCorpID),
-				Type:    TokenTypeAuthCorpPermanentCode,
-			}, func() (OpaqueToken, error) {
+			code, err = c.TokenProvider.Refresh(c.tokenOf(TokenTypeAuthCorpPermanentCode), func() (OpaqueToken, error) {
 				return nil, errors.New("missing auth corp permanent code")
 			})
 		}
@@ -91,54 +184,85 @@ func (c *Client) AuthCorpAccessToken() (string, error) {
 
 // AccessToken request or return cached AccessToken
 func (c *Client) AccessToken() (string, error) {
+	return secretOf(c.accessToken())
+}
+
+func (c *Client) accessToken() (*GenericToken, error) {
+	const missing = "unable to get access token: missing CorpSecret or PermanentCode"
 	switch {
-	case c.Conf.CorpID != "" && c.Conf.CorpSecret != "":
-		return c.TokenProvider.Refresh(&GenericToken{
-			OwnerID: c.Conf.CorpID,
-			Type:    TokenTypeAccessToken,
-		}, func() (OpaqueToken, error) {
+	case c.Conf.CorpID != "" && c.hasSecret(SecretCorpSecret):
+		return c.refreshWithSecret(TokenTypeAccessToken, SecretCorpSecret, func(c *Client) (OpaqueToken, error) {
 			return c.GetToken()
 		})
 	case c.Conf.SuiteID != "" && c.Conf.AuthCorpID != "":
-		return c.AuthCorpAccessToken()
+		return c.authCorpAccessToken()
+	case c.Conf.CorpID != "":
+		// provided by TokenProvider, e.g. TokenSourceProvider or a shared store
+		return c.refresh(c.tokenOf(TokenTypeAccessToken), func(*Client) (OpaqueToken, error) {
+			return nil, errors.New(missing)
+		})
 	default:
-		return "", errors.New("unable to get access token: missing CorpSecret or PermanentCode")
+		return nil, errors.New(missing)
 	}
 }
 
 // ProviderAccessToken request or return cached ProviderAccessToken
 func (c *Client) ProviderAccessToken() (string, error) {
-	return c.TokenProvider.Refresh(&GenericToken{
-		OwnerID: c.Conf.CorpID,
-		Type:    TokenTypeProviderAccessToken,
-	}, func() (OpaqueToken, error) {
+	return secretOf(c.providerAccessToken())
+}
+
+func (c *Client) providerAccessToken() (*GenericToken, error) {
+	return c.refreshWithSecret(TokenTypeProviderAccessToken, SecretProviderSecret, func(c *Client) (OpaqueToken, error) {
 		return c.GetProviderToken()
 	})
 }
 
 // SuiteAccessToken request or return cached SuiteAccessToken
 func (c *Client) SuiteAccessToken() (string, error) {
-	return c.TokenProvider.Refresh(&GenericToken{
-		OwnerID: c.Conf.SuiteID,
-		Type:    TokenTypeSuiteAccessToken,
-	}, func() (OpaqueToken, error) {
-		ticket := c.Conf.SuiteTicket
-		if ticket == "" {
-			var err error
-			ticket, err = c.TokenProvider.Refresh(&GenericToken{
-				OwnerID: c.Conf.SuiteID,
-				Type:    TokenTypeSuiteTicket,
-			}, func() (OpaqueToken, error) {
-				return nil, errors.New("missing suite ticket")
-			})
-			if err != nil {
-				return nil, err
-			}
+	return secretOf(c.suiteAccessToken())
+}
+
+func (c *Client) suiteAccessToken() (*GenericToken, error) {
+	return c.refreshWithSecret(TokenTypeSuiteAccessToken, SecretSuiteSecret, func(c *Client) (OpaqueToken, error) {
+		secret, err := c.secret(SecretSuiteSecret)
+		if err != nil {
+			return nil, err
+		}
+		ticket, err := c.suiteTicket()
+		if err != nil {
+			return nil, err
 		}
 		return c.ProviderGetSuiteToken(&ProviderGetSuiteTokenRequest{
 			SuiteID:     c.Conf.SuiteID,
-			SuiteSecret: c.Conf.SuiteSecret,
+			SuiteSecret: secret,
 			SuiteTicket: ticket,
 		})
 	})
 }
+
+// refresh by GenericTokenProvider if supported, the returned token has no expiry otherwise.
+// fetch is called with the detached Client, the token is shared by requests and may be refreshed in background
+// by TokenRefresher, so it is not canceled with the context of one request.
+func (c *Client) refresh(exp *GenericToken, fetch func(c *Client) (OpaqueToken, error)) (*GenericToken, error) {
+	bg := c.detached()
+	f := func() (OpaqueToken, error) {
+		return fetch(bg)
+	}
+	if p, ok := c.TokenProvider.(GenericTokenProvider); ok {
+		return p.RefreshToken(exp, f)
+	}
+	secret, err := c.TokenProvider.Refresh(exp, f)
+	if err != nil {
+		return nil, err
+	}
+	token := *exp
+	token.Secret = secret
+	return &token, nil
+}
+
+func secretOf(t *GenericToken, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	return t.Secret, nil
+}
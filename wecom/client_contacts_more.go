@@ -1,5 +1,10 @@
 package wecom
 
+import (
+	"encoding/json"
+	"strconv"
+)
+
 // SimpleListUserResponseItem is item model of SimpleListUserResponse.UserList
 type SimpleListUserResponseItem struct {
 	// UserID 成员UserID。对应管理端的帐号
@@ -186,3 +191,24 @@ type ListTagResponseItem struct {
 	// TagName 标签名
 	TagName string `json:"tagname"`
 }
+
+// UnmarshalJSON accept tagid as number, which is returned by the api, or string
+func (r *CreateTagResponse) UnmarshalJSON(data []byte) error {
+	type alias CreateTagResponse
+	var v struct {
+		alias
+		TagID json.Number `json:"tagid"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*r = CreateTagResponse(v.alias)
+	r.TagID = v.TagID.String()
+	return nil
+}
+
+// TagIDInt TagID as int, used by the requests of the tag
+func (r CreateTagResponse) TagIDInt() int {
+	id, _ := strconv.Atoi(r.TagID)
+	return id
+}
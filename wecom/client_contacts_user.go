@@ -91,13 +91,27 @@ func (c *Client) SimpleListUser(r *SimpleListUserRequest, opts ...interface{}) (
 func (c *Client) ListUser(r *ListUserRequest, opts ...interface{}) (out ListUserResponse, err error) {
 	err = c.Request.With(req.Request{
 		Method:  "GET",
-		URL:     "/cgi-bin/user/list_id",
+		URL:     "/cgi-bin/user/list",
 		Query:   r,
 		Options: opts,
 	}).Fetch(&out)
 	return
 }
 
+// ListUserID 获取成员ID列表
+// 获取企业成员的userid与对应的部门ID列表
+//
+// see https://developer.work.weixin.qq.com/document/path/96067
+func (c *Client) ListUserID(r *ListUserIDRequest, opts ...interface{}) (out ListUserIDResponse, err error) {
+	err = c.Request.With(req.Request{
+		Method:  "POST",
+		URL:     "/cgi-bin/user/list_id",
+		Body:    r,
+		Options: opts,
+	}).Fetch(&out)
+	return
+}
+
 // ConvertToOpenID userid与openid互换
 // userid转openid
 //
@@ -347,6 +361,30 @@ type ListUserResponse struct {
 	UserList []ListUserResponseItem `json:"userlist"  `
 }
 
+// ListUserIDRequest is request of Client.ListUserID
+type ListUserIDRequest struct {
+	// Cursor 用于分页查询的游标，字符串类型，由上一次调用返回，首次调用不填
+	Cursor string `json:"cursor,omitempty"  `
+	// Limit 分页，预期请求的数据量，取值范围 1 ~ 10000
+	Limit int `json:"limit,omitempty"  `
+}
+
+// ListUserIDResponse is response of Client.ListUserID
+type ListUserIDResponse struct {
+	// NextCursor 分页游标，下次请求时填写以获取之后分页的记录。如果该字段返回空则表示已没有更多数据
+	NextCursor string `json:"next_cursor"  `
+	// DeptUser 用户-部门关系列表
+	DeptUser []ListUserIDResponseItem `json:"dept_user"  `
+}
+
+// ListUserIDResponseItem is item of ListUserIDResponse.DeptUser
+type ListUserIDResponseItem struct {
+	// UserID 用户userid，当用户在多个部门下时会有多条记录
+	UserID string `json:"userid"  `
+	// Department 用户所属部门
+	Department int `json:"department"  `
+}
+
 // ConvertToOpenIDRequest is request of Client.ConvertToOpenID
 type ConvertToOpenIDRequest struct {
 	// UserID 企业内的成员id
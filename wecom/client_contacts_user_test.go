@@ -8,6 +8,7 @@ func init() {
 	registerClientAPIPath("/cgi-bin/user/batchdelete", "BatchDeleteUser", cRef.BatchDeleteUser)
 	registerClientAPIPath("/cgi-bin/user/simplelist", "SimpleListUser", cRef.SimpleListUser)
 	registerClientAPIPath("/cgi-bin/user/list", "ListUser", cRef.ListUser)
+	registerClientAPIPath("/cgi-bin/user/list_id", "ListUserID", cRef.ListUserID)
 	registerClientAPIPath("/cgi-bin/user/convert_to_openid", "ConvertToOpenID", cRef.ConvertToOpenID)
 	registerClientAPIPath("/cgi-bin/user/authsucc", "AuthSuccess", cRef.AuthSuccess)
 	registerClientAPIPath("/cgi-bin/batch/invite", "BatchInvite", cRef.BatchInvite)
@@ -0,0 +1,175 @@
+package wecom
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PermanentCodeStore store of the permanent codes of auth corps, saved after ProviderGetPermanentCode
+type PermanentCodeStore interface {
+	// PermanentCode of authCorpID authorized to suiteID, empty if not authorized
+	PermanentCode(suiteID, authCorpID string) (string, error)
+}
+
+// PermanentCodeStoreFunc func as PermanentCodeStore
+type PermanentCodeStoreFunc func(suiteID, authCorpID string) (string, error)
+
+// PermanentCode implements PermanentCodeStore
+func (f PermanentCodeStoreFunc) PermanentCode(suiteID, authCorpID string) (string, error) {
+	return f(suiteID, authCorpID)
+}
+
+// ClientRegistry build the Client of auth corps on demand for a suite, the clients share the TokenProvider,
+// RateLimiter and Observer of the suite Client, clients not used for IdleTimeout are evicted.
+//
+//	registry := &wecom.ClientRegistry{
+//		Client:         wecom.NewClient(wecom.Conf{SuiteID: "", SuiteSecret: ""}),
+//		PermanentCodes: store,
+//	}
+//	client, err := registry.ForEvent(event)
+type ClientRegistry struct {
+	Client         *Client            // suite client, required
+	PermanentCodes PermanentCodeStore // nil to load AuthCorpPermanentCode from the TokenProvider
+	IdleTimeout    time.Duration      // evict clients not used for this duration, default 30m, negative to disable
+
+	mu        sync.Mutex
+	clients   map[string]*registryEntry
+	lastSweep time.Time
+}
+
+type registryEntry struct {
+	client   *Client
+	lastUsed time.Time
+}
+
+// Get the Client of authCorpID, built with the permanent code on first use
+func (r *ClientRegistry) Get(authCorpID string) (*Client, error) {
+	if authCorpID == "" {
+		return nil, errors.New("missing AuthCorpID")
+	}
+	now := timeNow()
+	r.mu.Lock()
+	r.sweep(now)
+	if e, ok := r.clients[authCorpID]; ok {
+		e.lastUsed = now
+		r.mu.Unlock()
+		return e.client, nil
+	}
+	r.mu.Unlock()
+
+	client, err := r.build(authCorpID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.clients[authCorpID]; ok {
+		// built concurrently
+		e.lastUsed = now
+		return e.client, nil
+	}
+	if r.clients == nil {
+		r.clients = make(map[string]*registryEntry)
+	}
+	r.clients[authCorpID] = &registryEntry{client: client, lastUsed: now}
+	return client, nil
+}
+
+// ForEvent the Client of the auth corp which sent the push event
+func (r *ClientRegistry) ForEvent(e *CommonPushEvent) (*Client, error) {
+	if e.SuiteID != "" && e.SuiteID != r.Client.Conf.SuiteID {
+		return nil, errors.Errorf("event of suite %v, expected %v", e.SuiteID, r.Client.Conf.SuiteID)
+	}
+	return r.Get(e.AuthCorpID)
+}
+
+// Remove the Client of authCorpID, e.g. after cancel_auth or the permanent code changed
+func (r *ClientRegistry) Remove(authCorpID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, authCorpID)
+}
+
+// Len number of the built clients
+func (r *ClientRegistry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.clients)
+}
+
+// Evict clients not used for IdleTimeout, return the number of evicted clients
+func (r *ClientRegistry) Evict() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.evict(timeNow())
+}
+
+func (r *ClientRegistry) idleTimeout() time.Duration {
+	if r.IdleTimeout == 0 {
+		return 30 * time.Minute
+	}
+	return r.IdleTimeout
+}
+
+// sweep evict at most once every IdleTimeout, no background goroutine is needed
+func (r *ClientRegistry) sweep(now time.Time) {
+	if d := r.idleTimeout(); d > 0 && now.Sub(r.lastSweep) >= d {
+		r.evict(now)
+	}
+}
+
+func (r *ClientRegistry) evict(now time.Time) (n int) {
+	r.lastSweep = now
+	d := r.idleTimeout()
+	if d < 0 {
+		return 0
+	}
+	for k, v := range r.clients {
+		if now.Sub(v.lastUsed) >= d {
+			delete(r.clients, k)
+			n++
+		}
+	}
+	return
+}
+
+func (r *ClientRegistry) build(authCorpID string) (*Client, error) {
+	if r.Client == nil || r.Client.Conf.SuiteID == "" {
+		return nil, errors.New("ClientRegistry: missing suite Client")
+	}
+	conf := r.Client.Conf
+	conf.AuthCorpID = authCorpID
+	conf.AuthCorpPermanentCode = ""
+	// AccessToken of the auth corp client is the AuthCorpAccessToken
+	conf.CorpSecret = ""
+	if conf.SecretProvider != nil {
+		conf.SecretProvider = withoutCorpSecret{conf.SecretProvider}
+	}
+	if r.PermanentCodes != nil {
+		code, err := r.PermanentCodes.PermanentCode(conf.SuiteID, authCorpID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "load permanent code of %v", authCorpID)
+		}
+		if code == "" {
+			return nil, errors.Errorf("auth corp %v not authorized to suite %v", authCorpID, conf.SuiteID)
+		}
+		conf.AuthCorpPermanentCode = code
+	}
+	return r.Client.With(conf), nil
+}
+
+// withoutCorpSecret SecretProvider of the auth corp client, the CorpSecret of the suite is not used
+type withoutCorpSecret struct {
+	SecretProvider
+}
+
+// Secret implements SecretProvider
+func (p withoutCorpSecret) Secret(name string) (string, error) {
+	if name == SecretCorpSecret {
+		return "", nil
+	}
+	return p.SecretProvider.Secret(name)
+}
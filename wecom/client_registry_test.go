@@ -0,0 +1,119 @@
+package wecom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientRegistry(t *testing.T) {
+	var corpTokens int32
+	mux := chi.NewMux()
+	mux.Post("/cgi-bin/service/get_suite_token", func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, SuiteTokenResponse{SuiteAccessToken: "SuiteAccessToken", ExpiresIn: 7200})
+	})
+	mux.Post("/cgi-bin/service/get_corp_token", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "SuiteAccessToken", r.URL.Query().Get("suite_access_token"))
+		in := ProviderGetCorpTokenRequest{}
+		assert.NoError(t, render.DecodeJSON(r.Body, &in))
+		assert.Equal(t, "code-"+in.AuthCorpID, in.PermanentCode)
+		atomic.AddInt32(&corpTokens, 1)
+		render.JSON(w, r, ProviderGetCorpTokenResponse{AccessToken: "token-" + in.AuthCorpID, ExpiresIn: 7200})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	suite := NewClient(Conf{
+		CorpID:      "CorpID",
+		CorpSecret:  "CorpSecret",
+		SuiteID:     "SuiteID",
+		SuiteSecret: "SuiteSecret",
+		SuiteTicket: "SuiteTicket",
+	})
+	suite.Request.BaseURL = server.URL
+	registry := &ClientRegistry{
+		Client: suite,
+		PermanentCodes: PermanentCodeStoreFunc(func(suiteID, authCorpID string) (string, error) {
+			assert.Equal(t, "SuiteID", suiteID)
+			if authCorpID == "unknown" {
+				return "", nil
+			}
+			return "code-" + authCorpID, nil
+		}),
+	}
+
+	a, err := registry.Get("A")
+	assert.NoError(t, err)
+	assert.Equal(t, "A", a.Conf.AuthCorpID)
+	assert.Equal(t, "code-A", a.Conf.AuthCorpPermanentCode)
+	assert.Empty(t, a.Conf.CorpSecret)
+	assert.Same(t, suite.TokenProvider, a.TokenProvider)
+
+	token, err := a.AccessToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "token-A", token)
+
+	b, err := registry.ForEvent(&CommonPushEvent{SuiteID: "SuiteID", AuthCorpID: "B"})
+	assert.NoError(t, err)
+	token, err = b.AccessToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "token-B", token)
+
+	again, err := registry.Get("A")
+	assert.NoError(t, err)
+	assert.Same(t, a, again)
+	_, err = again.AccessToken()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&corpTokens))
+	assert.Equal(t, 2, registry.Len())
+
+	_, err = registry.Get("unknown")
+	assert.Error(t, err)
+	_, err = registry.Get("")
+	assert.Error(t, err)
+	_, err = registry.ForEvent(&CommonPushEvent{SuiteID: "Other", AuthCorpID: "A"})
+	assert.Error(t, err)
+
+	registry.Remove("B")
+	assert.Equal(t, 1, registry.Len())
+}
+
+func TestClientRegistryEvict(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	registry := &ClientRegistry{
+		Client:      NewClient(Conf{SuiteID: "SuiteID", SuiteSecret: "SuiteSecret"}),
+		IdleTimeout: time.Minute,
+	}
+	_, err := registry.Get("A")
+	assert.NoError(t, err)
+	now = now.Add(30 * time.Second)
+	_, err = registry.Get("B")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, registry.Evict())
+
+	// A is evicted by the sweep of Get
+	now = now.Add(time.Minute)
+	_, err = registry.Get("B")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, registry.Len())
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, 1, registry.Evict())
+	assert.Equal(t, 0, registry.Len())
+
+	registry.IdleTimeout = -1
+	_, err = registry.Get("A")
+	assert.NoError(t, err)
+	now = now.Add(time.Hour)
+	assert.Equal(t, 0, registry.Evict())
+	assert.Equal(t, 1, registry.Len())
+}
@@ -60,12 +60,16 @@ func (c *Client) SuiteGetUserDetail(r *SuiteGetUserDetailRequest) (out SuiteGetU
 }
 
 func (c *Client) GetProviderToken() (out ProviderTokenResponse, err error) {
+	secret, err := c.secret(SecretProviderSecret)
+	if err != nil {
+		return
+	}
 	err = c.Request.With(req.Request{
 		Method: http.MethodPost,
 		URL:    "/cgi-bin/service/get_provider_token",
 		Body: map[string]string{
 			"corpid":          c.Conf.CorpID,
-			"provider_secret": c.Conf.ProviderSecret,
+			"provider_secret": secret,
 		},
 		Options: []interface{}{WithoutAccessToken},
 	}).Fetch(&out)
@@ -88,6 +92,7 @@ func (c *Client) ProviderGetLoginInfo(r *ProviderGetLoginInfoRequest) (out Provi
 		URL:    "/cgi-bin/service/get_login_info",
 		Body:   r,
 		Options: []interface{}{func(m *middlewareOptions) {
+			m.TokenType = TokenTypeProviderAccessToken
 			m.GetToken = func(c *Client, r *req.Request) (string, string, error) {
 				// 名字是 access_token 但内容为 provider_access_token
 				token, err := c.ProviderAccessToken()
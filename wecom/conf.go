@@ -7,7 +7,7 @@ type Conf struct {
 	ProviderSecret        string // 第三方 secret
 	SuiteID               string // 第三方应用 ID
 	SuiteSecret           string // 第三方应用 secret
-	SuiteTicket           string // 第三方应用 ticket - 无法主动获取 - 会尝试从 Provider 获取
+	SuiteTicket           string // 第三方应用 ticket - 无法主动获取 - 优先使用 SuiteTicketStore，会尝试从 Provider 获取
 	AuthCorpID            string // 企业 ID
 	AuthCorpPermanentCode string // 第三方企业永久授权码 - 会尝试从 Provider 获取
 
@@ -15,5 +15,11 @@ type Conf struct {
 	// ProviderToken  string // used for push event
 	// EncodingAESKey string
 
-	TokenProvider TokenProvider `json:"-"`
+	TokenProvider    TokenProvider    `json:"-"`
+	SecretProvider   SecretProvider   `json:"-"` // load CorpSecret, ProviderSecret and SuiteSecret if not set, re-read when rejected - e.g. EnvSecretProvider
+	RateLimiter      RateLimiter      `json:"-"` // limit request rate - default no limit
+	Backoff          *Backoff         `json:"-"` // retry when frequency limited, nil to fail fast - e.g. DefaultBackoff
+	Observer         Observer         `json:"-"` // observe api calls - e.g. TracingObserver, MetricsObserver
+	Logger           Logger           `json:"-"` // log token refresh errors, api calls at debug level - default DefaultLogger without api calls
+	SuiteTicketStore SuiteTicketStore `json:"-"` // latest suite_ticket saved from callback - e.g. TokenSuiteTicketStore
 }
@@ -0,0 +1,263 @@
+package wecom
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfFromEnv load Conf from WECOM_ prefixed env, see ConfFromEnvPrefix
+func ConfFromEnv() (Conf, error) {
+	return ConfFromEnvPrefix("WECOM_")
+}
+
+// ConfFromEnvPrefix load Conf from env, every secret can be read from the file of <KEY>_FILE
+//
+//	WECOM_CORP_ID
+//	WECOM_AGENT_ID
+//	WECOM_CORP_SECRET
+//	WECOM_PROVIDER_SECRET
+//	WECOM_SUITE_ID
+//	WECOM_SUITE_SECRET
+//	WECOM_SUITE_TICKET
+//	WECOM_AUTH_CORP_ID
+//	WECOM_AUTH_CORP_PERMANENT_CODE
+func ConfFromEnvPrefix(prefix string) (conf Conf, err error) {
+	var agentID string
+	for _, v := range []struct {
+		Key   string
+		Value *string
+	}{
+		{"CORP_ID", &conf.CorpID},
+		{"AGENT_ID", &agentID},
+		{"CORP_SECRET", &conf.CorpSecret},
+		{"PROVIDER_SECRET", &conf.ProviderSecret},
+		{"SUITE_ID", &conf.SuiteID},
+		{"SUITE_SECRET", &conf.SuiteSecret},
+		{"SUITE_TICKET", &conf.SuiteTicket},
+		{"AUTH_CORP_ID", &conf.AuthCorpID},
+		{"AUTH_CORP_PERMANENT_CODE", &conf.AuthCorpPermanentCode},
+	} {
+		if *v.Value, err = lookupEnv(prefix + v.Key); err != nil {
+			return
+		}
+	}
+	if agentID != "" {
+		if conf.AgentID, err = strconv.Atoi(agentID); err != nil {
+			return conf, errors.Wrapf(err, "invalid %vAGENT_ID", prefix)
+		}
+	}
+	return conf, conf.Validate()
+}
+
+// lookupEnv value of key, or content of file from key_FILE
+func lookupEnv(key string) (string, error) {
+	if v := os.Getenv(key); v != "" {
+		return v, nil
+	}
+	fn := os.Getenv(key + "_FILE")
+	if fn == "" {
+		return "", nil
+	}
+	return readSecretFile(fn)
+}
+
+func readSecretFile(fn string) (string, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return "", errors.Wrapf(err, "read secret file %v", fn)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// NewClientFromEnv NewClient by ConfFromEnv
+func NewClientFromEnv() (*Client, error) {
+	conf, err := ConfFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conf), nil
+}
+
+// Validate required fields of every configured app mode - self-built, provider, suite and auth corp,
+// secrets not set are loaded from SecretProvider
+func (c Conf) Validate() error {
+	if c.SecretProvider != nil {
+		for _, name := range []string{SecretCorpSecret, SecretProviderSecret, SecretSuiteSecret} {
+			if secretOfConf(&c, name) != "" {
+				continue
+			}
+			v, err := c.SecretProvider.Secret(name)
+			if err != nil {
+				return errors.Wrapf(err, "wecom: load %v", name)
+			}
+			switch name {
+			case SecretCorpSecret:
+				c.CorpSecret = v
+			case SecretProviderSecret:
+				c.ProviderSecret = v
+			case SecretSuiteSecret:
+				c.SuiteSecret = v
+			}
+		}
+	}
+	var errs []string
+	check := func(mode string, fields ...string) {
+		var missing []string
+		for i := 0; i < len(fields); i += 2 {
+			if fields[i+1] == "" {
+				missing = append(missing, fields[i])
+			}
+		}
+		if len(missing) > 0 {
+			errs = append(errs, mode+" app missing "+strings.Join(missing, ", "))
+		}
+	}
+	configured := false
+	if c.CorpSecret != "" {
+		configured = true
+		check("self-built", "CorpID", c.CorpID)
+	}
+	if c.ProviderSecret != "" {
+		configured = true
+		check("provider", "CorpID", c.CorpID)
+	}
+	if c.AuthCorpID != "" || c.AuthCorpPermanentCode != "" {
+		configured = true
+		permanentCode := c.AuthCorpPermanentCode
+		if c.TokenProvider != nil {
+			// loaded by TokenProvider
+			permanentCode = "-"
+		}
+		check("auth corp", "SuiteID", c.SuiteID, "SuiteSecret", c.SuiteSecret, "AuthCorpID", c.AuthCorpID, "AuthCorpPermanentCode", permanentCode)
+	} else if c.SuiteID != "" || c.SuiteSecret != "" || c.SuiteTicket != "" {
+		configured = true
+		check("suite", "SuiteID", c.SuiteID, "SuiteSecret", c.SuiteSecret)
+	}
+	if !configured {
+		return errors.New("wecom: no app configured, requires CorpSecret for self-built app, ProviderSecret for provider, SuiteSecret for suite or AuthCorpID for auth corp")
+	}
+	if len(errs) > 0 {
+		return errors.New("wecom: incomplete conf: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ConfFile config of multi named apps, in YAML or JSON
+//
+//	apps:
+//	  default:
+//	    corp_id: ww0000000000000000
+//	    agent_id: 1000002
+//	    corp_secret_file: /run/secrets/wecom_corp_secret
+//	  suite:
+//	    suite_id: ww0000000000000001
+//	    suite_secret: secret
+type ConfFile struct {
+	Apps map[string]AppConf `json:"apps" yaml:"apps"`
+}
+
+// AppConf of ConfFile, secrets can be read from the *_file
+type AppConf struct {
+	CorpID                    string `json:"corp_id" yaml:"corp_id"`
+	AgentID                   int    `json:"agent_id" yaml:"agent_id"`
+	CorpSecret                string `json:"corp_secret" yaml:"corp_secret"`
+	CorpSecretFile            string `json:"corp_secret_file" yaml:"corp_secret_file"`
+	ProviderSecret            string `json:"provider_secret" yaml:"provider_secret"`
+	ProviderSecretFile        string `json:"provider_secret_file" yaml:"provider_secret_file"`
+	SuiteID                   string `json:"suite_id" yaml:"suite_id"`
+	SuiteSecret               string `json:"suite_secret" yaml:"suite_secret"`
+	SuiteSecretFile           string `json:"suite_secret_file" yaml:"suite_secret_file"`
+	SuiteTicket               string `json:"suite_ticket" yaml:"suite_ticket"`
+	SuiteTicketFile           string `json:"suite_ticket_file" yaml:"suite_ticket_file"`
+	AuthCorpID                string `json:"auth_corp_id" yaml:"auth_corp_id"`
+	AuthCorpPermanentCode     string `json:"auth_corp_permanent_code" yaml:"auth_corp_permanent_code"`
+	AuthCorpPermanentCodeFile string `json:"auth_corp_permanent_code_file" yaml:"auth_corp_permanent_code_file"`
+}
+
+// Conf resolve the secret files and validate
+func (a AppConf) Conf() (conf Conf, err error) {
+	conf = Conf{
+		CorpID:     a.CorpID,
+		AgentID:    a.AgentID,
+		SuiteID:    a.SuiteID,
+		AuthCorpID: a.AuthCorpID,
+	}
+	for _, v := range []struct {
+		Value  *string
+		Inline string
+		File   string
+	}{
+		{&conf.CorpSecret, a.CorpSecret, a.CorpSecretFile},
+		{&conf.ProviderSecret, a.ProviderSecret, a.ProviderSecretFile},
+		{&conf.SuiteSecret, a.SuiteSecret, a.SuiteSecretFile},
+		{&conf.SuiteTicket, a.SuiteTicket, a.SuiteTicketFile},
+		{&conf.AuthCorpPermanentCode, a.AuthCorpPermanentCode, a.AuthCorpPermanentCodeFile},
+	} {
+		*v.Value = v.Inline
+		if v.Inline == "" && v.File != "" {
+			if *v.Value, err = readSecretFile(v.File); err != nil {
+				return
+			}
+		}
+	}
+	return conf, conf.Validate()
+}
+
+// LoadConfFile load ConfFile, format by extension - .yaml, .yml or .json
+func LoadConfFile(fn string) (*ConfFile, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	f := &ConfFile{}
+	switch strings.ToLower(filepath.Ext(fn)) {
+	case ".json":
+		err = json.Unmarshal(data, f)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, f)
+	default:
+		return nil, errors.Errorf("wecom: unsupported conf file %v", fn)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "wecom: parse conf file %v", fn)
+	}
+	return f, nil
+}
+
+// Conf of named app
+func (f *ConfFile) Conf(name string) (Conf, error) {
+	a, ok := f.Apps[name]
+	if !ok {
+		return Conf{}, errors.Errorf("wecom: app %q not found", name)
+	}
+	conf, err := a.Conf()
+	if err != nil {
+		return conf, errors.Wrapf(err, "app %q", name)
+	}
+	return conf, nil
+}
+
+// Confs of all apps
+func (f *ConfFile) Confs() (map[string]Conf, error) {
+	names := make([]string, 0, len(f.Apps))
+	for k := range f.Apps {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	out := make(map[string]Conf, len(names))
+	for _, name := range names {
+		conf, err := f.Conf(name)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = conf
+	}
+	return out, nil
+}
@@ -0,0 +1,100 @@
+package wecom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "secret")
+	assert.NoError(t, os.WriteFile(fn, []byte("CorpSecret\n"), 0o600))
+
+	t.Setenv("WECOM_CORP_ID", "CorpID")
+	t.Setenv("WECOM_AGENT_ID", "1000002")
+	t.Setenv("WECOM_CORP_SECRET_FILE", fn)
+	conf, err := ConfFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, Conf{CorpID: "CorpID", AgentID: 1000002, CorpSecret: "CorpSecret"}, conf)
+
+	t.Setenv("WECOM_CORP_SECRET", "Override")
+	conf, err = ConfFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, "Override", conf.CorpSecret)
+
+	t.Setenv("WECOM_SUITE_TICKET", "SuiteTicket")
+	_, err = ConfFromEnv()
+	assert.EqualError(t, err, "wecom: incomplete conf: suite app missing SuiteID, SuiteSecret")
+
+	t.Setenv("WECOM_AGENT_ID", "x")
+	_, err = ConfFromEnv()
+	assert.Error(t, err)
+}
+
+func TestConfValidate(t *testing.T) {
+	for _, test := range []struct {
+		conf Conf
+		err  string
+	}{
+		{Conf{}, "wecom: no app configured, requires CorpSecret for self-built app, ProviderSecret for provider, SuiteSecret for suite or AuthCorpID for auth corp"},
+		{Conf{CorpID: "CorpID", CorpSecret: "CorpSecret"}, ""},
+		{Conf{CorpSecret: "CorpSecret"}, "wecom: incomplete conf: self-built app missing CorpID"},
+		{Conf{ProviderSecret: "ProviderSecret"}, "wecom: incomplete conf: provider app missing CorpID"},
+		{Conf{SuiteID: "SuiteID"}, "wecom: incomplete conf: suite app missing SuiteSecret"},
+		{Conf{SuiteID: "SuiteID", SuiteSecret: "SuiteSecret", AuthCorpID: "AuthCorpID"}, "wecom: incomplete conf: auth corp app missing AuthCorpPermanentCode"},
+		{Conf{SuiteID: "SuiteID", SuiteSecret: "SuiteSecret", AuthCorpID: "AuthCorpID", TokenProvider: &TokenCache{}}, ""},
+		{Conf{CorpID: "CorpID", ProviderSecret: "ProviderSecret", AuthCorpID: "AuthCorpID"}, "wecom: incomplete conf: auth corp app missing SuiteID, SuiteSecret, AuthCorpPermanentCode"},
+	} {
+		err := test.conf.Validate()
+		if test.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, test.err)
+		}
+	}
+}
+
+func TestLoadConfFile(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret")
+	assert.NoError(t, os.WriteFile(secret, []byte("SuiteSecret"), 0o600))
+	yml := filepath.Join(dir, "wecom.yaml")
+	assert.NoError(t, os.WriteFile(yml, []byte(`
+apps:
+  default:
+    corp_id: CorpID
+    agent_id: 1000002
+    corp_secret: CorpSecret
+  suite:
+    suite_id: SuiteID
+    suite_secret_file: `+secret+`
+  broken:
+    auth_corp_id: AuthCorpID
+`), 0o600))
+
+	f, err := LoadConfFile(yml)
+	assert.NoError(t, err)
+	conf, err := f.Conf("default")
+	assert.NoError(t, err)
+	assert.Equal(t, Conf{CorpID: "CorpID", AgentID: 1000002, CorpSecret: "CorpSecret"}, conf)
+	conf, err = f.Conf("suite")
+	assert.NoError(t, err)
+	assert.Equal(t, Conf{SuiteID: "SuiteID", SuiteSecret: "SuiteSecret"}, conf)
+	_, err = f.Conf("broken")
+	assert.EqualError(t, err, `app "broken": wecom: incomplete conf: auth corp app missing SuiteID, SuiteSecret, AuthCorpPermanentCode`)
+	_, err = f.Conf("none")
+	assert.Error(t, err)
+	_, err = f.Confs()
+	assert.Error(t, err)
+
+	js := filepath.Join(dir, "wecom.json")
+	assert.NoError(t, os.WriteFile(js, []byte(`{"apps":{"default":{"corp_id":"CorpID","corp_secret":"CorpSecret"}}}`), 0o600))
+	f, err = LoadConfFile(js)
+	assert.NoError(t, err)
+	confs, err := f.Confs()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Conf{"default": {CorpID: "CorpID", CorpSecret: "CorpSecret"}}, confs)
+}
@@ -2,6 +2,8 @@ package wecom
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -43,3 +45,27 @@ func TestMarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateTagResponseUnmarshal(t *testing.T) {
+	for _, in := range []string{`{"errcode":0,"tagid":12}`, `{"tagid":"12"}`} {
+		out := CreateTagResponse{}
+		if assert.NoError(t, json.Unmarshal([]byte(in), &out)) {
+			assert.Equal(t, "12", out.TagID)
+			assert.Equal(t, 12, out.TagIDInt())
+		}
+	}
+
+	// error response is not decoded as success
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"errcode":40071,"errmsg":"UserTag Name Already Exist"}`))
+	}))
+	defer server.Close()
+	client := NewClient(Conf{})
+	client.Request.BaseURL = server.URL
+	out, err := client.CreateTag(&CreateTagRequest{TagName: "UI"}, WithoutAccessToken)
+	er, ok := AsGenericResponse(err)
+	if assert.True(t, ok) {
+		assert.Equal(t, 40071, er.ErrorCode)
+	}
+	assert.Empty(t, out.TagID)
+}
@@ -3,6 +3,7 @@ package wecom
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 type contextKey string
@@ -24,3 +25,12 @@ func FromContext(ctx context.Context) *Client {
 func NewContext(ctx context.Context, v *Client) context.Context {
 	return context.WithValue(ctx, ClientContextKey, v)
 }
+
+// detachedContext keeps the values of the parent without its cancellation and deadline
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
@@ -2,8 +2,12 @@ package wecom
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -11,3 +15,41 @@ func TestCtx(t *testing.T) {
 	assert.Equal(t, "wecom.contextKey(Client)", ClientContextKey.String())
 	assert.Nil(t, FromContext(context.Background()))
 }
+
+func TestClientWithContext(t *testing.T) {
+	mux := chi.NewMux()
+	mux.Get("/cgi-bin/getcallbackip", func(writer http.ResponseWriter, request *http.Request) {
+		render.JSON(writer, request, IPListResponse{IPList: []string{"127.0.0.1"}})
+	})
+	mux.Get("/cgi-bin/user/get", func(writer http.ResponseWriter, request *http.Request) {
+		render.JSON(writer, request, GetUserResponse{UserID: request.URL.Query().Get("userid")})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(Conf{})
+	client.Request.BaseURL = server.URL
+	client.Request.Options = append(client.Request.Options, WithoutAccessToken)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := client.WithContext(ctx)
+	assert.Equal(t, c, FromContext(c.Context()))
+	assert.Equal(t, client, FromContext(client.Context()))
+
+	_, err := c.GetCallbackIP()
+	assert.NoError(t, err)
+	{
+		out, err := client.GetUser(&GetUserRequest{UserID: "test"}, WithRequestContext(ctx))
+		assert.NoError(t, err)
+		assert.Equal(t, "test", out.UserID)
+	}
+
+	cancel()
+	_, err = c.GetCallbackIP()
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = client.GetUser(&GetUserRequest{UserID: "test"}, WithRequestContext(ctx))
+	assert.ErrorIs(t, err, context.Canceled)
+	// origin client not affected
+	_, err = client.GetCallbackIP()
+	assert.NoError(t, err)
+}
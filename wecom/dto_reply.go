@@ -0,0 +1,152 @@
+package wecom
+
+import "encoding/xml"
+
+/*
+被动回复消息
+https://developer.work.weixin.qq.com/document/path/90241
+*/
+
+// CDATA xml text as CDATA section, text fields of the passive reply and the encrypted envelope are CDATA as documented
+type CDATA struct {
+	Value string `xml:",cdata"`
+}
+
+// ReplyMessage common fields of the passive reply
+type ReplyMessage struct {
+	XMLName xml.Name `xml:"xml" json:"-"`
+	// ToUsername 成员UserID
+	ToUsername CDATA `xml:"ToUserName"`
+	// FromUsername 企业微信CorpID
+	FromUsername CDATA `xml:"FromUserName"`
+	// CreateTime 消息创建时间（整型）
+	CreateTime int64 `xml:"CreateTime"`
+	// MsgType 消息类型
+	MsgType CDATA `xml:"MsgType"`
+}
+
+// newReplyMessage reply to the sender of the push event
+func newReplyMessage(e *CommonPushEvent, msgType string) ReplyMessage {
+	return ReplyMessage{
+		ToUsername:   CDATA{Value: e.FromUsername},
+		FromUsername: CDATA{Value: e.ToUsername},
+		CreateTime:   timeNow().Unix(),
+		MsgType:      CDATA{Value: msgType},
+	}
+}
+
+// TextReply 文本消息
+type TextReply struct {
+	ReplyMessage
+	// Content 文本消息内容,最长不超过2048个字节，超过将截断
+	Content CDATA `xml:"Content"`
+}
+
+// NewTextReply reply text to the sender of e
+func NewTextReply(e *CommonPushEvent, content string) *TextReply {
+	return &TextReply{ReplyMessage: newReplyMessage(e, "text"), Content: CDATA{Value: content}}
+}
+
+// ReplyMedia media of the reply
+type ReplyMedia struct {
+	// MediaID 通过素材管理接口上传多媒体文件得到 MediaId
+	MediaID CDATA `xml:"MediaId"`
+}
+
+// ImageReply 图片消息
+type ImageReply struct {
+	ReplyMessage
+	Image ReplyMedia `xml:"Image"`
+}
+
+// NewImageReply reply image to the sender of e
+func NewImageReply(e *CommonPushEvent, mediaID string) *ImageReply {
+	return &ImageReply{ReplyMessage: newReplyMessage(e, "image"), Image: ReplyMedia{MediaID: CDATA{Value: mediaID}}}
+}
+
+// VoiceReply 语音消息
+type VoiceReply struct {
+	ReplyMessage
+	Voice ReplyMedia `xml:"Voice"`
+}
+
+// NewVoiceReply reply voice to the sender of e
+func NewVoiceReply(e *CommonPushEvent, mediaID string) *VoiceReply {
+	return &VoiceReply{ReplyMessage: newReplyMessage(e, "voice"), Voice: ReplyMedia{MediaID: CDATA{Value: mediaID}}}
+}
+
+// ReplyVideo video of VideoReply
+type ReplyVideo struct {
+	// MediaID 通过素材管理接口上传多媒体文件得到 MediaId
+	MediaID CDATA `xml:"MediaId"`
+	// Title 视频消息的标题,不超过128个字节，超过会自动截断
+	Title CDATA `xml:"Title"`
+	// Description 视频消息的描述,不超过512个字节，超过会自动截断
+	Description CDATA `xml:"Description"`
+}
+
+// VideoReply 视频消息
+type VideoReply struct {
+	ReplyMessage
+	Video ReplyVideo `xml:"Video"`
+}
+
+// NewVideoReply reply video to the sender of e
+func NewVideoReply(e *CommonPushEvent, video ReplyVideo) *VideoReply {
+	return &VideoReply{ReplyMessage: newReplyMessage(e, "video"), Video: video}
+}
+
+// ReplyArticle article of NewsReply
+type ReplyArticle struct {
+	// Title 标题，不超过128个字节，超过会自动截断
+	Title CDATA `xml:"Title"`
+	// Description 描述，不超过512个字节，超过会自动截断
+	Description CDATA `xml:"Description"`
+	// PicURL 图文消息的图片链接，支持JPG、PNG格式，较好的效果为大图640x320，小图80x80
+	PicURL CDATA `xml:"PicUrl"`
+	// URL 点击后跳转的链接
+	URL CDATA `xml:"Url"`
+}
+
+// NewsReply 图文消息
+type NewsReply struct {
+	ReplyMessage
+	// ArticleCount 图文消息的数量，不超过8
+	ArticleCount int `xml:"ArticleCount"`
+	// Articles 图文消息
+	Articles []ReplyArticle `xml:"Articles>item"`
+}
+
+// NewNewsReply reply news to the sender of e
+func NewNewsReply(e *CommonPushEvent, articles ...ReplyArticle) *NewsReply {
+	return &NewsReply{ReplyMessage: newReplyMessage(e, "news"), ArticleCount: len(articles), Articles: articles}
+}
+
+// ReplyButton button of UpdateButtonReply
+type ReplyButton struct {
+	// ReplaceName 点击卡片按钮后显示的按钮名称
+	ReplaceName CDATA `xml:"ReplaceName"`
+}
+
+// UpdateButtonReply 更新点击用户的按钮文案，回复 template_card_event
+type UpdateButtonReply struct {
+	ReplyMessage
+	Button ReplyButton `xml:"Button"`
+}
+
+// NewUpdateButtonReply replace the name of the clicked button of template card
+func NewUpdateButtonReply(e *CommonPushEvent, replaceName string) *UpdateButtonReply {
+	return &UpdateButtonReply{ReplyMessage: newReplyMessage(e, "update_button"), Button: ReplyButton{ReplaceName: CDATA{Value: replaceName}}}
+}
+
+// UpdateTemplateCardReply 更新点击用户的整张卡片，回复 template_card_event
+type UpdateTemplateCardReply struct {
+	ReplyMessage
+	// TemplateCard 卡片内容，xml 字段同更新模版卡片消息，例如 CardType, MainTitle, ReplaceText
+	TemplateCard interface{} `xml:"TemplateCard"`
+}
+
+// NewUpdateTemplateCardReply replace the template card clicked by the user
+func NewUpdateTemplateCardReply(e *CommonPushEvent, card interface{}) *UpdateTemplateCardReply {
+	return &UpdateTemplateCardReply{ReplyMessage: newReplyMessage(e, "update_template_card"), TemplateCard: card}
+}
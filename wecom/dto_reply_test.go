@@ -0,0 +1,53 @@
+package wecom
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplyMarshal(t *testing.T) {
+	timeNow = func() time.Time { return time.Unix(1348831860, 0) }
+	defer func() { timeNow = time.Now }()
+
+	e := &CommonPushEvent{ToUsername: "CorpID", FromUsername: "UserID"}
+	const head = `<xml><ToUserName><![CDATA[UserID]]></ToUserName><FromUserName><![CDATA[CorpID]]></FromUserName><CreateTime>1348831860</CreateTime>`
+	for _, test := range []struct {
+		reply    interface{}
+		expected string
+	}{
+		{NewTextReply(e, "a < b & c"), `<MsgType><![CDATA[text]]></MsgType><Content><![CDATA[a < b & c]]></Content></xml>`},
+		{NewImageReply(e, "image"), `<MsgType><![CDATA[image]]></MsgType><Image><MediaId><![CDATA[image]]></MediaId></Image></xml>`},
+		{NewVoiceReply(e, "voice"), `<MsgType><![CDATA[voice]]></MsgType><Voice><MediaId><![CDATA[voice]]></MediaId></Voice></xml>`},
+		{
+			NewVideoReply(e, ReplyVideo{MediaID: CDATA{Value: "video"}, Title: CDATA{Value: "title"}}),
+			`<MsgType><![CDATA[video]]></MsgType><Video><MediaId><![CDATA[video]]></MediaId><Title><![CDATA[title]]></Title>` +
+				`<Description></Description></Video></xml>`,
+		},
+		{
+			NewNewsReply(e, ReplyArticle{Title: CDATA{Value: "a"}, URL: CDATA{Value: "https://a?b=1&c=2"}}),
+			`<MsgType><![CDATA[news]]></MsgType><ArticleCount>1</ArticleCount><Articles>` +
+				`<item><Title><![CDATA[a]]></Title><Description></Description><PicUrl></PicUrl><Url><![CDATA[https://a?b=1&c=2]]></Url></item></Articles></xml>`,
+		},
+		{NewUpdateButtonReply(e, "done"), `<MsgType><![CDATA[update_button]]></MsgType><Button><ReplaceName><![CDATA[done]]></ReplaceName></Button></xml>`},
+		{
+			NewUpdateTemplateCardReply(e, struct {
+				CardType    string
+				ReplaceText string
+			}{"button_interaction", "done"}),
+			`<MsgType><![CDATA[update_template_card]]></MsgType><TemplateCard><CardType>button_interaction</CardType><ReplaceText>done</ReplaceText></TemplateCard></xml>`,
+		},
+	} {
+		data, err := xml.Marshal(test.reply)
+		assert.NoError(t, err)
+		assert.Equal(t, head+test.expected, string(data))
+	}
+
+	// decoded back
+	reply := TextReply{}
+	assert.NoError(t, xml.Unmarshal([]byte(head+`<MsgType><![CDATA[text]]></MsgType><Content><![CDATA[a < b]]></Content></xml>`), &reply))
+	assert.Equal(t, "UserID", reply.ToUsername.Value)
+	assert.Equal(t, "a < b", reply.Content.Value)
+}
@@ -0,0 +1,262 @@
+package wecom
+
+import (
+	"github.com/fish0607/go-wecom/commons/errhint"
+	"github.com/pkg/errors"
+)
+
+/*
+全局错误码
+https://developer.work.weixin.qq.com/document/path/90313
+
+The table is curated by hand from the page above as of 2026-10-17, only the codes commonly met by the apis of
+this package are listed, not generated. ErrorCodeMessage return empty for other codes, the hint in errmsg is used.
+*/
+
+var _errorCodeMessages = map[int]string{
+	-1:     "系统繁忙",
+	6000:   "数据版本冲突",
+	40001:  "不合法的secret参数",
+	40003:  "无效的UserID",
+	40004:  "不合法的媒体文件类型",
+	40005:  "不合法的type参数",
+	40006:  "不合法的文件大小",
+	40007:  "不合法的media_id参数",
+	40008:  "不合法的msgtype参数",
+	40013:  "不合法的CorpID",
+	40014:  "不合法的access_token",
+	40029:  "不合法的oauth_code",
+	40031:  "不合法的UserID列表",
+	40033:  "不合法的请求字符",
+	40035:  "不合法的参数",
+	40050:  "chatid不存在",
+	40056:  "不合法的agentid",
+	40057:  "不合法的callbackurl或者callbackurl验证失败",
+	40058:  "不合法的参数",
+	40063:  "参数为空",
+	40066:  "不合法的部门列表",
+	40068:  "不合法的标签ID",
+	40071:  "不合法的标签名字",
+	40072:  "不合法的名字",
+	40077:  "不合法的预授权码",
+	40078:  "不合法的临时授权码",
+	40080:  "不合法的SuiteSecret",
+	40082:  "不合法的suitetoken",
+	40083:  "不合法的suiteid",
+	40084:  "不合法的永久授权码",
+	40085:  "不合法的suiteticket",
+	40086:  "不合法的第三方应用appid",
+	40088:  "jobid不存在",
+	40091:  "secret不合法",
+	40096:  "不合法的外部联系人userid",
+	40097:  "该成员尚未离职",
+	40098:  "成员尚未实名认证",
+	40099:  "外部联系人的数量已达上限",
+	40100:  "此用户的外部联系人已经在转移流程中",
+	41001:  "缺少access_token参数",
+	41002:  "缺少corpid参数",
+	41004:  "缺少secret参数",
+	41006:  "缺少media_id参数",
+	41008:  "缺少auth code参数",
+	41009:  "缺少userid参数",
+	41010:  "缺少url参数",
+	41011:  "缺少agentid参数",
+	41021:  "缺少suite_id参数",
+	41022:  "缺少suite_access_token参数",
+	41023:  "缺少suite_ticket参数",
+	41025:  "缺少permanent_code参数",
+	42001:  "access_token已过期",
+	42007:  "pre_auth_code已过期",
+	42009:  "suite_access_token已过期",
+	42012:  "jsapi_ticket不可用",
+	44001:  "多媒体文件为空",
+	44004:  "文本消息content参数为空",
+	45001:  "多媒体文件大小超过限制",
+	45002:  "消息内容大小超过限制",
+	45009:  "接口调用超过限制",
+	45011:  "API调用太频繁，请稍候再试",
+	45024:  "帐号数量超过上限",
+	45026:  "触发删除用户数的保护",
+	45033:  "接口并发调用超过限制",
+	46004:  "指定的用户不存在",
+	48002:  "API接口无权限调用",
+	48003:  "不合法的suite_id",
+	48004:  "授权关系无效",
+	48005:  "API接口已废弃",
+	48006:  "接口权限被收回",
+	49004:  "签名不匹配",
+	50001:  "redirect_url未登记可信域名",
+	50002:  "成员不在权限范围",
+	50003:  "应用已禁用",
+	60001:  "部门长度不符合限制",
+	60003:  "部门ID不存在",
+	60004:  "父部门不存在",
+	60005:  "不允许删除有成员的部门",
+	60006:  "不允许删除有子部门的部门",
+	60008:  "部门已存在",
+	60011:  "指定的成员/部门/标签参数无权限",
+	60020:  "访问ip不在白名单之中",
+	60102:  "UserID已存在",
+	60103:  "手机号码不合法",
+	60111:  "UserID不存在",
+	60121:  "找不到该成员",
+	60123:  "无效的部门id",
+	60124:  "无效的父部门id",
+	60125:  "非法部门名字",
+	81013:  "UserID、部门ID、标签ID全部非法或无权限",
+	84061:  "不存在外部联系人的关系",
+	301002: "无权限操作指定的应用",
+}
+
+// ErrorCodeMessage return the description of errcode, empty for unknown code
+func ErrorCodeMessage(code int) string {
+	return _errorCodeMessages[code]
+}
+
+func newCodeError(code int) *GenericResponse {
+	return &GenericResponse{ErrorCode: code, ErrorMessage: _errorCodeMessages[code]}
+}
+
+// sentinel errors, works with errors.Is
+var (
+	ErrSystemBusy              = newCodeError(-1)
+	ErrInvalidSecret           = newCodeError(40001)
+	ErrInvalidUserID           = newCodeError(40003)
+	ErrInvalidCorpID           = newCodeError(40013)
+	ErrInvalidAccessToken      = newCodeError(40014)
+	ErrInvalidSuiteToken       = newCodeError(40082)
+	ErrInvalidPermanentCode    = newCodeError(40084)
+	ErrInvalidSuiteTicket      = newCodeError(40085)
+	ErrAccessTokenExpired      = newCodeError(42001)
+	ErrSuiteAccessTokenExpired = newCodeError(42009)
+	ErrAPIFreqOutOfLimit       = newCodeError(45009)
+	ErrAPITooFrequent          = newCodeError(45011)
+	ErrAPIConcurrentOutOfLimit = newCodeError(45033)
+	ErrUserNotFound            = newCodeError(46004)
+	ErrAPIForbidden            = newCodeError(48002)
+	ErrInvalidAuthorization    = newCodeError(48004)
+	ErrAPIRevoked              = newCodeError(48006)
+	ErrUserOutOfScope          = newCodeError(50002)
+	ErrDepartmentNotFound      = newCodeError(60003)
+	ErrNoPrivilege             = newCodeError(60011)
+	ErrIPNotAllowed            = newCodeError(60020)
+	ErrUserIDNotFound          = newCodeError(60111)
+)
+
+// isTokenErrorCode invalid or expired access_token/suite_access_token
+func isTokenErrorCode(code int) bool {
+	switch code {
+	case 40014, 42001, 40082, 42009:
+		return true
+	}
+	return false
+}
+
+// isSecretErrorCode corp, provider or suite secret is invalid
+func isSecretErrorCode(code int) bool {
+	switch code {
+	case 40001, 40080, 40091, 41004:
+		return true
+	}
+	return false
+}
+
+// isRateLimitErrorCode frequency limit of api or corp
+func isRateLimitErrorCode(code int) bool {
+	switch code {
+	case 45009, 45011, 45033:
+		return true
+	}
+	return false
+}
+
+func isPermissionDeniedErrorCode(code int) bool {
+	switch code {
+	case 48002, 48004, 48006, 50002, 60011, 60020, 81013, 301002:
+		return true
+	}
+	return false
+}
+
+func isNotFoundErrorCode(code int) bool {
+	switch code {
+	case 40003, 40050, 40088, 46004, 60003, 60111, 60121, 84061:
+		return true
+	}
+	return false
+}
+
+func isRetryableErrorCode(code int) bool {
+	return code == -1 || code == 6000 || isRateLimitErrorCode(code)
+}
+
+// AsGenericResponse find the GenericResponse in err chain
+func AsGenericResponse(err error) (*GenericResponse, bool) {
+	var p *GenericResponse
+	if errors.As(err, &p) && p != nil {
+		return p, true
+	}
+	var v GenericResponse
+	if errors.As(err, &v) {
+		return &v, true
+	}
+	return nil, false
+}
+
+func isErrorCode(err error, f func(code int) bool) bool {
+	r, ok := AsGenericResponse(err)
+	return ok && f(r.ErrorCode)
+}
+
+// IsTokenError access_token or suite_access_token is invalid or expired
+func IsTokenError(err error) bool {
+	return isErrorCode(err, isTokenErrorCode)
+}
+
+// IsSecretError corp, provider or suite secret is invalid, e.g. rotated in the console
+func IsSecretError(err error) bool {
+	return isErrorCode(err, isSecretErrorCode)
+}
+
+// IsRateLimited api frequency or concurrency out of limit
+func IsRateLimited(err error) bool {
+	return isErrorCode(err, isRateLimitErrorCode)
+}
+
+// IsPermissionDenied api or resource is not allowed for current app
+func IsPermissionDenied(err error) bool {
+	return isErrorCode(err, isPermissionDeniedErrorCode)
+}
+
+// IsNotFound user, department, chat or job not exists
+func IsNotFound(err error) bool {
+	return isErrorCode(err, isNotFoundErrorCode)
+}
+
+// IsRetryable error is transient, request can retry later
+func IsRetryable(err error) bool {
+	return isErrorCode(err, isRetryableErrorCode)
+}
+
+// Is match errcode of target, works with errors.Is
+func (r GenericResponse) Is(target error) bool {
+	switch t := target.(type) {
+	case *GenericResponse:
+		return t != nil && t.ErrorCode == r.ErrorCode
+	case GenericResponse:
+		return t.ErrorCode == r.ErrorCode
+	}
+	return false
+}
+
+// Message of errmsg without hint
+//
+//	invalid access_token, hint: [1620000000_1_d8c8d], from ip: 1.1.1.1, more info at https://open.work.weixin.qq.com/devtool/query?e=40014
+func (r GenericResponse) Message() string {
+	return errhint.Message(r.ErrorMessage)
+}
+
+// Hint of errmsg, used to query error detail at https://open.work.weixin.qq.com/devtool/query
+func (r GenericResponse) Hint() string {
+	return errhint.Hint(r.ErrorMessage)
+}
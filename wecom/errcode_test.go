@@ -0,0 +1,40 @@
+package wecom
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCode(t *testing.T) {
+	er := GenericResponse{
+		ErrorCode:    40014,
+		ErrorMessage: "invalid access_token, hint: [1620000000_1_d8c8d], from ip: 1.1.1.1, more info at https://open.work.weixin.qq.com/devtool/query?e=40014",
+	}
+	err := errors.Wrap(er.AsError(), "get user")
+	assert.True(t, errors.Is(err, ErrInvalidAccessToken))
+	assert.False(t, errors.Is(err, ErrAccessTokenExpired))
+	assert.True(t, IsTokenError(err))
+	assert.False(t, IsRateLimited(err))
+	assert.False(t, IsRetryable(err))
+	assert.Equal(t, "invalid access_token", er.Message())
+	assert.Equal(t, "1620000000_1_d8c8d", er.Hint())
+	assert.Equal(t, "不合法的access_token", ErrorCodeMessage(er.ErrorCode))
+
+	r, ok := AsGenericResponse(err)
+	assert.True(t, ok)
+	assert.Equal(t, er, *r)
+
+	// value error
+	assert.True(t, IsRateLimited(GenericResponse{ErrorCode: 45033}))
+	assert.True(t, IsRetryable(GenericResponse{ErrorCode: -1}))
+	assert.True(t, IsPermissionDenied(GenericResponse{ErrorCode: 48002}))
+	assert.True(t, IsNotFound(GenericResponse{ErrorCode: 60111}))
+	assert.True(t, errors.Is(GenericResponse{ErrorCode: 60111}, ErrUserIDNotFound))
+
+	assert.False(t, IsNotFound(errors.New("60111")))
+	assert.False(t, IsNotFound(nil))
+	assert.Equal(t, "test", GenericResponse{ErrorMessage: "test"}.Message())
+	assert.Equal(t, "", GenericResponse{ErrorMessage: "test"}.Hint())
+}
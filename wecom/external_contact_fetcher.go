@@ -0,0 +1,219 @@
+package wecom
+
+import (
+	"context"
+	"sync"
+)
+
+// ExternalContactFetcher fetch external contact details in parallel, the rate limit is handled by Client.RateLimiter and Client.Backoff
+//
+//	f := &wecom.ExternalContactFetcher{Client: client, Concurrency: 5}
+//	for r := range f.Fetch(ctx, ids) {
+//		if r.Error != nil {
+//			// failed for r.ExternalUserID only
+//		}
+//	}
+type ExternalContactFetcher struct {
+	Client      *Client
+	Concurrency int // parallel requests, default 10
+	BatchSize   int // userids per BatchGetByUser, default and max 100
+}
+
+// ExternalContactResult of Fetch
+type ExternalContactResult struct {
+	ExternalUserID string
+	Contact        GetExternalContactResponse // follow_user of all pages
+	Error          error
+}
+
+// ExternalContactByUserResult of FetchByUser
+type ExternalContactByUserResult struct {
+	UserID   string
+	Contacts []BatchGetByUserResponseExternalContactList
+	Error    error
+}
+
+func (f *ExternalContactFetcher) concurrency() int {
+	if f.Concurrency <= 0 {
+		return 10
+	}
+	return f.Concurrency
+}
+
+func (f *ExternalContactFetcher) batchSize() int {
+	if f.BatchSize <= 0 || f.BatchSize > 100 {
+		return 100
+	}
+	return f.BatchSize
+}
+
+// Fetch GetExternalContact for every external_userid from ids, the returned chan is closed when ids is closed and all done, or ctx is done
+func (f *ExternalContactFetcher) Fetch(ctx context.Context, ids <-chan string) <-chan ExternalContactResult {
+	out := make(chan ExternalContactResult)
+	client := f.Client.WithContext(ctx)
+	var wg sync.WaitGroup
+	for i := 0; i < f.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				id, ok := receive(ctx, ids)
+				if !ok {
+					return
+				}
+				r := ExternalContactResult{ExternalUserID: id}
+				r.Contact, r.Error = getExternalContact(client, id)
+				if !send(ctx, out, r) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// getExternalContact with all pages of follow_user
+func getExternalContact(c *Client, id string) (out GetExternalContactResponse, err error) {
+	req := &GetExternalContactRequest{ExternalUserID: id}
+	for {
+		var page GetExternalContactResponse
+		page, err = c.GetExternalContact(req)
+		if err != nil {
+			return
+		}
+		if req.Cursor == "" {
+			out = page
+		} else {
+			out.FollowUser = append(out.FollowUser, page.FollowUser...)
+		}
+		if page.NextCursor == "" || page.NextCursor == req.Cursor {
+			out.NextCursor = ""
+			return
+		}
+		req.Cursor = page.NextCursor
+	}
+}
+
+// FetchByUser BatchGetByUser for every follow user from userIDs, users are batched by BatchSize,
+// a batch failed by an invalid userid is retried user by user to find out the failed one.
+func (f *ExternalContactFetcher) FetchByUser(ctx context.Context, userIDs <-chan string) <-chan ExternalContactByUserResult {
+	out := make(chan ExternalContactByUserResult)
+	client := f.Client.WithContext(ctx)
+	batches := make(chan []string)
+	go func() {
+		defer close(batches)
+		var batch []string
+		for {
+			id, ok := receive(ctx, userIDs)
+			if ok {
+				batch = append(batch, id)
+			}
+			if len(batch) > 0 && (!ok || len(batch) >= f.batchSize()) {
+				select {
+				case batches <- batch:
+				case <-ctx.Done():
+					return
+				}
+				batch = nil
+			}
+			if !ok {
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < f.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				batch, ok := receive(ctx, batches)
+				if !ok {
+					return
+				}
+				for _, r := range batchGetByUser(client, batch) {
+					if !send(ctx, out, r) {
+						return
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// FetchByFollowUsers FetchByUser for all users from GetFollowUserList
+func (f *ExternalContactFetcher) FetchByFollowUsers(ctx context.Context) (<-chan ExternalContactByUserResult, error) {
+	users, err := f.Client.WithContext(ctx).GetFollowUserList()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(chan string, len(users.FollowUser))
+	for _, v := range users.FollowUser {
+		ids <- v
+	}
+	close(ids)
+	return f.FetchByUser(ctx, ids), nil
+}
+
+func batchGetByUser(c *Client, users []string) []ExternalContactByUserResult {
+	contacts, err := c.IterateExternalContactByUser(&BatchGetByUserRequest{UserIDList: users, Limit: 100}).All()
+	if len(users) > 1 && isErrorCode(err, isUserErrorCode) {
+		var out []ExternalContactByUserResult
+		for _, v := range users {
+			out = append(out, batchGetByUser(c, []string{v})...)
+		}
+		return out
+	}
+	results := make([]ExternalContactByUserResult, len(users))
+	index := make(map[string]int, len(users))
+	for i, v := range users {
+		results[i] = ExternalContactByUserResult{UserID: v, Error: err}
+		index[v] = i
+	}
+	if err != nil {
+		return results
+	}
+	for _, v := range contacts {
+		if i, ok := index[v.FollowInfo.UserID]; ok {
+			results[i].Contacts = append(results[i].Contacts, v)
+		}
+	}
+	return results
+}
+
+// isUserErrorCode errors caused by one of the userids, other errors - e.g. rate limited, token error or
+// canceled context - fail the whole batch, retry user by user only makes more requests.
+func isUserErrorCode(code int) bool {
+	switch code {
+	case 40003, 40031, 46004, 50002, 60111, 84061:
+		return true
+	}
+	return false
+}
+
+func receive[T any](ctx context.Context, c <-chan T) (v T, ok bool) {
+	select {
+	case v, ok = <-c:
+	case <-ctx.Done():
+	}
+	return
+}
+
+func send[T any](ctx context.Context, c chan<- T, v T) bool {
+	select {
+	case c <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,148 @@
+package wecom
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExternalContactFetcher(t *testing.T) {
+	mux := chi.NewMux()
+	mux.Get("/cgi-bin/gettoken", func(writer http.ResponseWriter, request *http.Request) {
+		render.JSON(writer, request, TokenResponse{AccessToken: "AccessToken", ExpiresIn: 7200})
+	})
+	var running, maxRunning int32
+	mux.Get("/cgi-bin/externalcontact/get", func(writer http.ResponseWriter, request *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		id := request.URL.Query().Get("external_userid")
+		if id == "bad" {
+			render.JSON(writer, request, GenericResponse{ErrorCode: 40096, ErrorMessage: "invalid external userid"})
+			return
+		}
+		out := GetExternalContactResponse{}
+		out.ExternalContact.ExternalUserID = id
+		switch request.URL.Query().Get("cursor") {
+		case "":
+			out.FollowUser = []GetExternalContactResponseFollowUser{{UserID: "a"}}
+			if id == "many" {
+				out.NextCursor = "1"
+			}
+		case "1":
+			out.FollowUser = []GetExternalContactResponseFollowUser{{UserID: "b"}}
+		}
+		render.JSON(writer, request, out)
+	})
+	mux.Get("/cgi-bin/externalcontact/get_follow_user_list", func(writer http.ResponseWriter, request *http.Request) {
+		render.JSON(writer, request, GetFollowUserListResponse{FollowUser: []string{"u1", "bad", "u2"}})
+	})
+	var batches int32
+	mux.Post("/cgi-bin/externalcontact/batch/get_by_user", func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&batches, 1)
+		r := BatchGetByUserRequest{}
+		assert.NoError(t, render.Decode(request, &r))
+		out := BatchGetByUserResponse{}
+		for _, v := range r.UserIDList {
+			if v == "limited" {
+				render.JSON(writer, request, GenericResponse{ErrorCode: 45009, ErrorMessage: "api freq out of limit"})
+				return
+			}
+			if v == "bad" {
+				render.JSON(writer, request, GenericResponse{ErrorCode: 40003, ErrorMessage: "invalid userid"})
+				return
+			}
+			item := BatchGetByUserResponseExternalContactList{}
+			item.FollowInfo.UserID = v
+			item.ExternalContact.ExternalUserID = v + "-" + r.Cursor
+			out.ExternalContactList = append(out.ExternalContactList, item)
+		}
+		if r.Cursor == "" {
+			out.NextCursor = "1"
+		}
+		render.JSON(writer, request, out)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(Conf{CorpID: "CorpID", CorpSecret: "CorpSecret"})
+	client.Request.BaseURL = server.URL
+	f := &ExternalContactFetcher{Client: client, Concurrency: 3}
+
+	{
+		ids := make(chan string)
+		go func() {
+			defer close(ids)
+			for _, v := range []string{"e1", "bad", "many", "e2", "e3", "e4", "e5"} {
+				ids <- v
+			}
+		}()
+		results := map[string]ExternalContactResult{}
+		for r := range f.Fetch(context.Background(), ids) {
+			results[r.ExternalUserID] = r
+		}
+		assert.Len(t, results, 7)
+		assert.Error(t, results["bad"].Error)
+		assert.NoError(t, results["e1"].Error)
+		assert.Equal(t, "e1", results["e1"].Contact.ExternalContact.ExternalUserID)
+		assert.Len(t, results["many"].Contact.FollowUser, 2)
+		assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(3))
+	}
+	{
+		out, err := f.FetchByFollowUsers(context.Background())
+		assert.NoError(t, err)
+		var users []string
+		for r := range out {
+			users = append(users, r.UserID)
+			if r.UserID == "bad" {
+				assert.Error(t, r.Error)
+				continue
+			}
+			assert.NoError(t, r.Error)
+			assert.Len(t, r.Contacts, 2)
+		}
+		sort.Strings(users)
+		assert.Equal(t, []string{"bad", "u1", "u2"}, users)
+		// failed batch, then retry by user
+		assert.Equal(t, int32(1+2*2+1), atomic.LoadInt32(&batches))
+	}
+	{
+		// rate limited batch fails as a whole without requests of every user
+		atomic.StoreInt32(&batches, 0)
+		noRetry := client.WithContext(context.Background())
+		noRetry.Backoff = &Backoff{}
+		f := &ExternalContactFetcher{Client: noRetry, Concurrency: 3}
+		ids := make(chan string, 3)
+		for _, v := range []string{"u1", "limited", "u2"} {
+			ids <- v
+		}
+		close(ids)
+		var n int
+		for r := range f.FetchByUser(context.Background(), ids) {
+			n++
+			assert.True(t, IsRateLimited(r.Error))
+		}
+		assert.Equal(t, 3, n)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&batches))
+	}
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		ids := make(chan string)
+		for range f.Fetch(ctx, ids) {
+			t.Fatal("unexpected result")
+		}
+	}
+}
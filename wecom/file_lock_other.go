@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package wecom
+
+// lockFile no file lock on this platform, only the in process lock is used
+func lockFile(fn string) (unlock func(), err error) {
+	return func() {}, nil
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package wecom
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile exclusive lock of fn, blocks until acquired
+func lockFile(fn string) (unlock func(), err error) {
+	f, err := os.OpenFile(fn, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		_ = f.Close()
+	}, nil
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package wecom
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile exclusive lock of fn, blocks until acquired
+func lockFile(fn string) (unlock func(), err error) {
+	f, err := os.OpenFile(fn, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	ol := new(windows.Overlapped)
+	if err = windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, ol); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return func() {
+		_ = windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+		_ = f.Close()
+	}, nil
+}
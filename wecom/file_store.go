@@ -0,0 +1,224 @@
+package wecom
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// FileStoreKeyEnv env of the FileStore key, can be read from the file of WECOM_TOKEN_CACHE_KEY_FILE
+const FileStoreKeyEnv = "WECOM_TOKEN_CACHE_KEY"
+
+const fileStoreAAD = "wecom-token-cache"
+
+// FileStore TokenLoadStore persisted to an AES-GCM encrypted file, shared by processes on the same host.
+// The file is locked during Load and replaced atomically on change, expired tokens are dropped on load.
+//
+//	store, err := wecom.NewFileStore("wecom-cache.bin") // key from WECOM_TOKEN_CACHE_KEY
+//	client := wecom.NewClient(wecom.Conf{TokenProvider: &wecom.TokenCache{Store: store}})
+type FileStore struct {
+	Path   string
+	Key    []byte // 32 bytes AES-256 key
+	Logger Logger // log the file can not be decrypted, default DefaultLogger
+
+	mu     sync.Mutex
+	tokens map[string]GenericToken
+	digest [sha256.Size]byte // of the file content last read or written
+}
+
+// NewFileStore create FileStore with key from FileStoreKeyEnv, the key is derived from the env value by sha256,
+// use a random value, e.g. openssl rand -base64 32
+func NewFileStore(path string) (*FileStore, error) {
+	v, err := lookupEnv(FileStoreKeyEnv)
+	if err != nil {
+		return nil, err
+	}
+	if v == "" {
+		return nil, errors.Errorf("wecom: missing %v", FileStoreKeyEnv)
+	}
+	key := sha256.Sum256([]byte(v))
+	return &FileStore{Path: path, Key: key[:]}, nil
+}
+
+// Load implements TokenLoadStore
+func (s *FileStore) Load(out *GenericToken, load func(last *GenericToken) (out *GenericToken, changed bool, err error)) (changed bool, err error) {
+	key, err := keyOfGenericToken(out)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockFile(s.Path + ".lock")
+	if err != nil {
+		return false, errors.Wrap(err, "lock token cache")
+	}
+	defer unlock()
+
+	if err = s.read(); err != nil {
+		return false, err
+	}
+	var data *GenericToken
+	if t, ok := s.tokens[key]; ok {
+		data = &t
+	}
+	o, c, err := load(data)
+	if err == nil && c {
+		data = o
+		if o.Secret == "" {
+			delete(s.tokens, key)
+		} else {
+			s.tokens[key] = *o
+		}
+		err = s.write()
+	}
+	if err == nil && data != nil {
+		*out = *data
+	}
+	return c, err
+}
+
+// Get the cached token
+func (s *FileStore) Get(out *GenericToken) (found bool, err error) {
+	_, err = s.Load(out, func(last *GenericToken) (*GenericToken, bool, error) {
+		found = last != nil
+		return last, false, nil
+	})
+	return
+}
+
+// OwnerIDs implements TokenOwnerLister
+func (s *FileStore) OwnerIDs(typ TokenType, ownerPrefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockFile(s.Path + ".lock")
+	if err != nil {
+		return nil, errors.Wrap(err, "lock token cache")
+	}
+	defer unlock()
+	if err = s.read(); err != nil {
+		return nil, err
+	}
+	var out []string
+	for k := range s.tokens {
+		if id, ok := ownerOfKey(k, typ); ok && strings.HasPrefix(id, ownerPrefix) {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+// read the file if its content changed since last read, the file is always read since mtime and size of
+// the encrypted file are not reliable. A file can not be decrypted or parsed, e.g. corrupted or encrypted by
+// other key, is used as empty and overwritten by the next write.
+func (s *FileStore) read() error {
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		s.tokens, s.digest = map[string]GenericToken{}, [sha256.Size]byte{}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(data)
+	if s.tokens != nil && digest == s.digest {
+		return nil
+	}
+	if _, err = s.gcm(); err != nil {
+		return errors.Wrap(err, "invalid token cache key")
+	}
+	s.tokens, s.digest = map[string]GenericToken{}, digest
+	plain, err := s.open(data)
+	if err != nil {
+		loggerOr(s.Logger).Warn("decrypt token cache failed, use empty cache", "path", s.Path, "error", err.Error())
+		return nil
+	}
+	var entries []*cacheEntry
+	if err = json.Unmarshal(plain, &entries); err != nil {
+		loggerOr(s.Logger).Warn("parse token cache failed, use empty cache", "path", s.Path, "error", err.Error())
+		return nil
+	}
+	for _, v := range entries {
+		if v.Key != "" && v.Data.IsValid() {
+			s.tokens[v.Key] = v.Data
+		}
+	}
+	return nil
+}
+
+// write to a temp file then rename
+func (s *FileStore) write() error {
+	entries := make([]*cacheEntry, 0, len(s.tokens))
+	for k, v := range s.tokens {
+		if v.IsValid() {
+			entries = append(entries, &cacheEntry{Key: k, Data: v})
+		}
+	}
+	plain, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	data, err := s.seal(plain)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrap(err, "write token cache")
+	}
+	if err = os.Rename(f.Name(), s.Path); err != nil {
+		return errors.Wrap(err, "write token cache")
+	}
+	s.digest = sha256.Sum256(data)
+	return nil
+}
+
+func (s *FileStore) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.Key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal nonce + ciphertext
+func (s *FileStore) seal(plain []byte) ([]byte, error) {
+	gcm, err := s.gcm()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, []byte(fileStoreAAD)), nil
+}
+
+func (s *FileStore) open(data []byte) ([]byte, error) {
+	gcm, err := s.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("invalid data")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(fileStoreAAD))
+}
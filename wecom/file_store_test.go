@@ -0,0 +1,116 @@
+package wecom
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "wecom-cache.bin")
+	t.Setenv(FileStoreKeyEnv, "")
+	_, err := NewFileStore(fn)
+	assert.Error(t, err)
+	t.Setenv(FileStoreKeyEnv, "secret")
+
+	// processes
+	var calls int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		store, err := NewFileStore(fn)
+		require.NoError(t, err)
+		tc := &TokenCache{Store: store}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := tc.Refresh(&GenericToken{Type: "A", OwnerID: "corp"}, func() (OpaqueToken, error) {
+				n := atomic.AddInt32(&calls, 1)
+				time.Sleep(10 * time.Millisecond)
+				return &GenericToken{Secret: "token-" + strconv.Itoa(int(n)), ExpiresIn: 7200}, nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "token-1", token)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls)
+
+	data, err := os.ReadFile(fn)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "token-1")
+
+	store, err := NewFileStore(fn)
+	require.NoError(t, err)
+	token := &GenericToken{Type: "A", OwnerID: "corp"}
+	found, err := store.Get(token)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "token-1", token.Secret)
+
+	// expired
+	tc := &TokenCache{Store: store}
+	_, err = tc.Refresh(&GenericToken{Type: "B"}, func() (OpaqueToken, error) {
+		return &GenericToken{Secret: "B", ExpiresIn: 1, ExpiresAt: time.Now().Add(-time.Second).Unix()}, nil
+	})
+	assert.NoError(t, err)
+	store, err = NewFileStore(fn)
+	require.NoError(t, err)
+	found, err = store.Get(&GenericToken{Type: "B"})
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	// invalidate
+	assert.NoError(t, tc.Invalidate(&GenericToken{Type: "A", OwnerID: "corp"}))
+	found, err = store.Get(&GenericToken{Type: "A", OwnerID: "corp"})
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	// same size and mtime changed by the other process
+	other, err := NewFileStore(fn)
+	require.NoError(t, err)
+	for _, v := range []string{"C1", "C2"} {
+		assert.NoError(t, tc.Invalidate(&GenericToken{Type: "C"}))
+		_, err = tc.Refresh(&GenericToken{Type: "C"}, func() (OpaqueToken, error) {
+			return &GenericToken{Secret: v, ExpiresIn: 7200}, nil
+		})
+		assert.NoError(t, err)
+		token := &GenericToken{Type: "C"}
+		_, err = other.Get(token)
+		assert.NoError(t, err)
+		assert.Equal(t, v, token.Secret)
+	}
+
+	// wrong key or corrupted, used as empty and overwritten
+	for _, key := range []string{"other", "corrupted"} {
+		if key == "corrupted" {
+			require.NoError(t, os.WriteFile(fn, []byte("corrupted"), 0o600))
+		}
+		t.Setenv(FileStoreKeyEnv, key)
+		store, err = NewFileStore(fn)
+		require.NoError(t, err)
+		found, err = store.Get(&GenericToken{Type: "A", OwnerID: "corp"})
+		assert.NoError(t, err)
+		assert.False(t, found)
+		token, err := (&TokenCache{Store: store}).Refresh(&GenericToken{Type: "A", OwnerID: "corp"}, func() (OpaqueToken, error) {
+			return &GenericToken{Secret: "token-" + key, ExpiresIn: 7200}, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "token-"+key, token)
+		store, err = NewFileStore(fn)
+		require.NoError(t, err)
+		found, err = store.Get(&GenericToken{Type: "A", OwnerID: "corp"})
+		assert.NoError(t, err)
+		assert.True(t, found)
+	}
+
+	// invalid key
+	_, err = (&FileStore{Path: fn, Key: []byte("short")}).Get(&GenericToken{Type: "A"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,165 @@
+package wecom
+
+// Iterator iterate items of cursor paged api, fetch next page when current page is consumed
+//
+//	it := client.IterateGroupChat(&wecom.ListGroupChatRequest{})
+//	it.Limit = 100
+//	for it.Next() {
+//		chat := it.Item()
+//	}
+//	if it.Err() != nil {
+//		// resume from it.Cursor() later
+//	}
+type Iterator[T any] struct {
+	Limit int // max items to iterate, 0 means no limit
+
+	fetch   func(cursor string, limit int) ([]T, string, error)
+	cursor  string // cursor of current page
+	next    string // cursor of next page
+	items   []T
+	index   int
+	count   int
+	started bool
+	err     error
+}
+
+// NewIterator create Iterator from a page fetcher, limit is the max items expected from the page, 0 for default page size
+func NewIterator[T any](cursor string, fetch func(cursor string, limit int) (items []T, next string, err error)) *Iterator[T] {
+	return &Iterator[T]{fetch: fetch, next: cursor}
+}
+
+// Next advance to next item, return false when all items are iterated, the limit is reached or an error occurred
+func (it *Iterator[T]) Next() bool {
+	if it.err != nil || (it.Limit > 0 && it.count >= it.Limit) {
+		return false
+	}
+	it.index++
+	for it.index >= len(it.items) {
+		if it.started && it.next == "" {
+			return false
+		}
+		limit := 0
+		if it.Limit > 0 {
+			limit = it.Limit - it.count
+		}
+		items, next, err := it.fetch(it.next, limit)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.started = true
+		it.cursor, it.next = it.next, next
+		it.items, it.index = items, 0
+	}
+	it.count++
+	return true
+}
+
+// Item return current item
+func (it *Iterator[T]) Item() T {
+	return it.items[it.index]
+}
+
+// Err return the error occurred during iterating
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Cursor to resume the iteration after current item is handled, items of current page may be iterated again.
+// Empty means from the beginning or all pages are consumed, check Done.
+func (it *Iterator[T]) Cursor() string {
+	if it.err != nil || !it.started {
+		return it.next
+	}
+	if it.index >= len(it.items)-1 {
+		// current page consumed
+		return it.next
+	}
+	return it.cursor
+}
+
+// Done all pages are consumed
+func (it *Iterator[T]) Done() bool {
+	return it.started && it.next == "" && it.index >= len(it.items)-1
+}
+
+// Each call fn for every item, stop when fn return false
+func (it *Iterator[T]) Each(fn func(item T) bool) error {
+	for it.Next() {
+		if !fn(it.Item()) {
+			break
+		}
+	}
+	return it.Err()
+}
+
+// All collect the remaining items
+func (it *Iterator[T]) All() ([]T, error) {
+	var out []T
+	for it.Next() {
+		out = append(out, it.Item())
+	}
+	return out, it.Err()
+}
+
+// pageLimit min of expected and page size, page size is used when expected is 0
+func pageLimit(size int, expected int) int {
+	if expected > 0 && (size == 0 || expected < size) {
+		return expected
+	}
+	return size
+}
+
+// IterateUserID iterate userid and department by ListUserID, start from r.Cursor
+func (c *Client) IterateUserID(r *ListUserIDRequest, opts ...interface{}) *Iterator[ListUserIDResponseItem] {
+	q := *r
+	return NewIterator(r.Cursor, func(cursor string, limit int) ([]ListUserIDResponseItem, string, error) {
+		q.Cursor, q.Limit = cursor, pageLimit(r.Limit, limit)
+		out, err := c.ListUserID(&q, opts...)
+		return out.DeptUser, out.NextCursor, err
+	})
+}
+
+// IterateExternalContactByUser iterate external contact by BatchGetByUser, start from r.Cursor
+func (c *Client) IterateExternalContactByUser(r *BatchGetByUserRequest, opts ...interface{}) *Iterator[BatchGetByUserResponseExternalContactList] {
+	q := *r
+	return NewIterator(r.Cursor, func(cursor string, limit int) ([]BatchGetByUserResponseExternalContactList, string, error) {
+		q.Cursor, q.Limit = cursor, pageLimit(r.Limit, limit)
+		out, err := c.BatchGetByUser(&q, opts...)
+		return out.ExternalContactList, out.NextCursor, err
+	})
+}
+
+// IterateGroupChat iterate group chat by ListGroupChat, start from r.Cursor, page size default to 1000
+func (c *Client) IterateGroupChat(r *ListGroupChatRequest, opts ...interface{}) *Iterator[ListGroupChatResponseGroupChatList] {
+	q := *r
+	size := r.Limit
+	if size == 0 {
+		size = 1000
+	}
+	return NewIterator(r.Cursor, func(cursor string, limit int) ([]ListGroupChatResponseGroupChatList, string, error) {
+		q.Cursor, q.Limit = cursor, pageLimit(size, limit)
+		out, err := c.ListGroupChat(&q, opts...)
+		return out.GroupChatList, out.NextCursor, err
+	})
+}
+
+// IterateMoment iterate moment by GetMomentList, start from r.Cursor
+func (c *Client) IterateMoment(r *GetMomentListRequest, opts ...interface{}) *Iterator[GetMomentListResponseMomentList] {
+	q := *r
+	return NewIterator(r.Cursor, func(cursor string, limit int) ([]GetMomentListResponseMomentList, string, error) {
+		q.Cursor, q.Limit = cursor, pageLimit(r.Limit, limit)
+		out, err := c.GetMomentList(&q, opts...)
+		return out.MomentList, out.NextCursor, err
+	})
+}
+
+// IterateGroupMessage iterate group message by GetGroupMessageListV2, start from r.Cursor
+func (c *Client) IterateGroupMessage(r *GetGroupMessageListV2Request, opts ...interface{}) *Iterator[GetGroupMessageListV2ResponseGroupMessageList] {
+	q := *r
+	return NewIterator(r.Cursor, func(cursor string, limit int) ([]GetGroupMessageListV2ResponseGroupMessageList, string, error) {
+		q.Cursor, q.Limit = cursor, pageLimit(r.Limit, limit)
+		out, err := c.GetGroupMessageListV2(&q, opts...)
+		return out.GroupMessageList, out.NextCursor, err
+	})
+}
@@ -0,0 +1,90 @@
+package wecom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterator(t *testing.T) {
+	mux := chi.NewMux()
+	mux.Get("/cgi-bin/gettoken", func(writer http.ResponseWriter, request *http.Request) {
+		render.JSON(writer, request, TokenResponse{AccessToken: "AccessToken", ExpiresIn: 7200})
+	})
+	var limits []int
+	mux.Post("/cgi-bin/externalcontact/groupchat/list", func(writer http.ResponseWriter, request *http.Request) {
+		r := ListGroupChatRequest{}
+		assert.NoError(t, render.Decode(request, &r))
+		limits = append(limits, r.Limit)
+		page := 0
+		if r.Cursor != "" {
+			page, _ = strconv.Atoi(r.Cursor)
+		}
+		if page > 2 {
+			render.JSON(writer, request, GenericResponse{ErrorCode: 40058, ErrorMessage: "invalid cursor"})
+			return
+		}
+		out := ListGroupChatResponse{}
+		for i := 0; i < 2; i++ {
+			out.GroupChatList = append(out.GroupChatList, ListGroupChatResponseGroupChatList{ChatID: strconv.Itoa(page*2 + i)})
+		}
+		if page < 2 {
+			out.NextCursor = strconv.Itoa(page + 1)
+		}
+		render.JSON(writer, request, out)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(Conf{CorpID: "CorpID", CorpSecret: "CorpSecret"})
+	client.Request.BaseURL = server.URL
+
+	ids := func(it *Iterator[ListGroupChatResponseGroupChatList]) (out []string) {
+		_ = it.Each(func(item ListGroupChatResponseGroupChatList) bool {
+			out = append(out, item.ChatID)
+			return true
+		})
+		return
+	}
+	{
+		it := client.IterateGroupChat(&ListGroupChatRequest{Limit: 2})
+		assert.Equal(t, []string{"0", "1", "2", "3", "4", "5"}, ids(it))
+		assert.NoError(t, it.Err())
+		assert.True(t, it.Done())
+		assert.Equal(t, "", it.Cursor())
+	}
+	{
+		limits = nil
+		it := client.IterateGroupChat(&ListGroupChatRequest{})
+		it.Limit = 3
+		assert.Equal(t, []string{"0", "1", "2"}, ids(it))
+		assert.Equal(t, []int{3, 1}, limits)
+		assert.False(t, it.Done())
+		// resume
+		it = client.IterateGroupChat(&ListGroupChatRequest{Cursor: it.Cursor()})
+		assert.Equal(t, []string{"2", "3", "4", "5"}, ids(it))
+	}
+	{
+		// early stop
+		it := client.IterateGroupChat(&ListGroupChatRequest{Limit: 2})
+		var got []string
+		assert.NoError(t, it.Each(func(item ListGroupChatResponseGroupChatList) bool {
+			got = append(got, item.ChatID)
+			return item.ChatID != "1"
+		}))
+		assert.Equal(t, []string{"0", "1"}, got)
+		assert.Equal(t, "1", it.Cursor())
+	}
+	{
+		it := client.IterateGroupChat(&ListGroupChatRequest{Cursor: "3"})
+		all, err := it.All()
+		assert.Empty(t, all)
+		assert.EqualError(t, err, "40058: invalid cursor")
+		assert.Equal(t, "3", it.Cursor())
+	}
+}
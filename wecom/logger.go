@@ -0,0 +1,175 @@
+package wecom
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Logger leveled logger, keysAndValues are alternating keys and values.
+// *slog.Logger implements Logger, use LogrusLogger for logrus.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// DefaultLogger used when no Logger is set, write Info and above to the standard log
+var DefaultLogger Logger = StdLogger{}
+
+// StdLogger Logger of standard log, Debug is dropped unless Verbose
+type StdLogger struct {
+	Logger  *log.Logger // default log.Default()
+	Verbose bool
+}
+
+func (l StdLogger) print(level string, msg string, keysAndValues []interface{}) {
+	var sb strings.Builder
+	sb.WriteString(level)
+	sb.WriteString(" ")
+	sb.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		sb.WriteString(" ")
+		if i+1 < len(keysAndValues) {
+			_, _ = fmt.Fprintf(&sb, "%v=%v", keysAndValues[i], keysAndValues[i+1])
+		} else {
+			_, _ = fmt.Fprintf(&sb, "%v", keysAndValues[i])
+		}
+	}
+	logger := l.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	_ = logger.Output(3, sb.String())
+}
+
+// Debug implements Logger
+func (l StdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	if l.Verbose {
+		l.print("DEBUG", msg, keysAndValues)
+	}
+}
+
+// Info implements Logger
+func (l StdLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.print("INFO", msg, keysAndValues)
+}
+
+// Warn implements Logger
+func (l StdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.print("WARN", msg, keysAndValues)
+}
+
+// Error implements Logger
+func (l StdLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.print("ERROR", msg, keysAndValues)
+}
+
+// NopLogger drop all logs
+type NopLogger struct{}
+
+// Debug implements Logger
+func (NopLogger) Debug(string, ...interface{}) {}
+
+// Info implements Logger
+func (NopLogger) Info(string, ...interface{}) {}
+
+// Warn implements Logger
+func (NopLogger) Warn(string, ...interface{}) {}
+
+// Error implements Logger
+func (NopLogger) Error(string, ...interface{}) {}
+
+// LogrusLogger Logger of logrus, keysAndValues are logged as fields
+type LogrusLogger struct {
+	Logger logrus.FieldLogger // default logrus.StandardLogger()
+}
+
+func (l LogrusLogger) entry(keysAndValues []interface{}) logrus.FieldLogger {
+	var logger logrus.FieldLogger = logrus.StandardLogger()
+	if l.Logger != nil {
+		logger = l.Logger
+	}
+	if len(keysAndValues) == 0 {
+		return logger
+	}
+	fields := make(logrus.Fields, len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		k := fmt.Sprint(keysAndValues[i])
+		if i+1 < len(keysAndValues) {
+			fields[k] = keysAndValues[i+1]
+		} else {
+			fields[k] = nil
+		}
+	}
+	return logger.WithFields(fields)
+}
+
+// Debug implements Logger
+func (l LogrusLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.entry(keysAndValues).Debug(msg)
+}
+
+// Info implements Logger
+func (l LogrusLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.entry(keysAndValues).Info(msg)
+}
+
+// Warn implements Logger
+func (l LogrusLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.entry(keysAndValues).Warn(msg)
+}
+
+// Error implements Logger
+func (l LogrusLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.entry(keysAndValues).Error(msg)
+}
+
+func loggerOr(l Logger) Logger {
+	if l == nil {
+		return DefaultLogger
+	}
+	return l
+}
+
+// LoggingObserver Observer log every api call at debug level, tokens and secrets in url are redacted, bodies are not logged
+type LoggingObserver struct {
+	Logger Logger
+}
+
+// StartCall implements Observer
+func (o LoggingObserver) StartCall(ctx context.Context, info *CallInfo) (context.Context, func(result *CallResult)) {
+	return ctx, func(result *CallResult) {
+		kv := []interface{}{
+			"method", info.Method,
+			"url", info.URL,
+			"status", result.StatusCode,
+			"errcode", result.ErrorCode,
+			"duration", result.Duration,
+		}
+		if result.Retries > 0 {
+			kv = append(kv, "retries", result.Retries)
+		}
+		if result.Error != nil {
+			kv = append(kv, "error", redactError(result.Error))
+		}
+		loggerOr(o.Logger).Debug("wecom call", kv...)
+	}
+}
+
+// redactError message of err, the url of transport error is redacted
+func redactError(err error) string {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		if u, perr := url.Parse(ue.URL); perr == nil {
+			return strings.ReplaceAll(err.Error(), ue.URL, RedactURL(u))
+		}
+	}
+	return err.Error()
+}
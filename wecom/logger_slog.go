@@ -0,0 +1,8 @@
+//go:build go1.21
+
+package wecom
+
+import "log/slog"
+
+// *slog.Logger can be used as Logger directly
+var _ Logger = (*slog.Logger)(nil)
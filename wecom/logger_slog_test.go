@@ -0,0 +1,18 @@
+//go:build go1.21
+
+package wecom
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	var logger Logger = slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	logger.Debug("wecom call", "url", "/cgi-bin/user/get")
+	assert.Contains(t, buf.String(), `level=DEBUG msg="wecom call" url=/cgi-bin/user/get`)
+}
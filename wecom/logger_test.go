@@ -0,0 +1,56 @@
+package wecom
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggingObserver(t *testing.T) {
+	ts := NewTestServer()
+	defer ts.Start()()
+
+	buf := &bytes.Buffer{}
+	ts.Client.Logger = StdLogger{Logger: log.New(buf, "", 0), Verbose: true}
+	_, err := ts.Client.GetUser(&GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "DEBUG wecom call method=GET url="+ts.URL()+"/cgi-bin/gettoken?corpid="+ts.CorpID+"&corpsecret=REDACTED status=200 errcode=0")
+	assert.Contains(t, buf.String(), "/cgi-bin/user/get?access_token=REDACTED&userid=zhangsan status=200")
+	assert.NotContains(t, buf.String(), ts.AccessToken)
+	assert.NotContains(t, buf.String(), ts.CorpSecret)
+
+	// transport error
+	buf.Reset()
+	ts.Client.Request.BaseURL = "http://127.0.0.1:1"
+	_, _ = ts.Client.GetUser(&GetUserRequest{UserID: "zhangsan"})
+	assert.Contains(t, buf.String(), "access_token=REDACTED")
+	assert.NotContains(t, buf.String(), ts.AccessToken)
+}
+
+func TestTokenCacheLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.Out = buf
+	tc := &TokenCache{Store: &SyncMapStore{}, Logger: LogrusLogger{Logger: logger}}
+	_, err := tc.Refresh(&GenericToken{Type: "A"}, func() (OpaqueToken, error) {
+		return &GenericToken{Secret: "1", ExpiresIn: 100, ExpiresAt: timeNow().Unix() + 10}, nil
+	})
+	assert.NoError(t, err)
+	token, err := tc.Refresh(&GenericToken{Type: "A"}, func() (OpaqueToken, error) {
+		return nil, assert.AnError
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", token)
+	assert.Contains(t, buf.String(), `level=warning msg="refresh token failed, keep using the valid one"`)
+	assert.Contains(t, buf.String(), "type=A")
+
+	buf.Reset()
+	tc.Logger = NopLogger{}
+	_, _ = tc.Refresh(&GenericToken{Type: "A"}, func() (OpaqueToken, error) {
+		return nil, assert.AnError
+	})
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/fish0607/go-wecom/wecom/callback"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DedupeKey handled callback event, see callback.DedupeKey
+type DedupeKey struct {
+	Key       string `gorm:"primaryKey;size:255"`
+	Done      bool   // false while the event is being handled
+	ExpiresAt int64  `gorm:"index"`
+	CreatedAt time.Time
+}
+
+// DedupeStore callback.DedupeStore backed by the DedupeKey table, replicas handle the event at most once
+//
+//	handler := &callback.Handler{Crypto: crypto, Handle: handle, Dedupe: &models.DedupeStore{DB: db}}
+type DedupeStore struct {
+	DB *gorm.DB
+}
+
+// Migrate the DedupeKey table
+func (s *DedupeStore) Migrate() error {
+	return s.DB.AutoMigrate(&DedupeKey{})
+}
+
+// Add implements callback.DedupeStore
+func (s *DedupeStore) Add(ctx context.Context, key string, ttl time.Duration) (callback.DedupeState, error) {
+	now := time.Now()
+	row := &DedupeKey{Key: key, ExpiresAt: now.Add(ttl).Unix()}
+	db := s.DB.WithContext(ctx)
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(row)
+	if res.Error != nil {
+		return 0, errors.Wrap(res.Error, "create dedupe key")
+	}
+	if res.RowsAffected > 0 {
+		return callback.DedupeAdded, nil
+	}
+	// take over the expired key
+	res = db.Model(&DedupeKey{}).
+		Where(map[string]interface{}{"key": key}).
+		Where("expires_at <= ?", now.Unix()).
+		Updates(map[string]interface{}{"done": false, "expires_at": row.ExpiresAt, "created_at": now})
+	if res.Error != nil {
+		return 0, errors.Wrap(res.Error, "update dedupe key")
+	}
+	if res.RowsAffected > 0 {
+		return callback.DedupeAdded, nil
+	}
+	if err := db.Where(map[string]interface{}{"key": key}).Take(row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// removed by the failed handler meanwhile, handled by the next retry
+			return callback.DedupeProcessing, nil
+		}
+		return 0, errors.Wrap(err, "get dedupe key")
+	}
+	if row.Done {
+		return callback.DedupeDone, nil
+	}
+	return callback.DedupeProcessing, nil
+}
+
+// Done implements callback.DedupeStore
+func (s *DedupeStore) Done(ctx context.Context, key string, ttl time.Duration) error {
+	return s.DB.WithContext(ctx).Model(&DedupeKey{}).
+		Where(map[string]interface{}{"key": key}).
+		Updates(map[string]interface{}{"done": true, "expires_at": time.Now().Add(ttl).Unix()}).Error
+}
+
+// Remove implements callback.DedupeStore
+func (s *DedupeStore) Remove(ctx context.Context, key string) error {
+	return s.DB.WithContext(ctx).Delete(&DedupeKey{Key: key}).Error
+}
+
+// Purge the expired keys
+func (s *DedupeStore) Purge(ctx context.Context) (int64, error) {
+	res := s.DB.WithContext(ctx).Where("expires_at <= ?", time.Now().Unix()).Delete(&DedupeKey{})
+	return res.RowsAffected, res.Error
+}
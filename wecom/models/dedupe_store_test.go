@@ -0,0 +1,68 @@
+package models
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fish0607/go-wecom/wecom/callback"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ callback.DedupeStore = (*DedupeStore)(nil)
+
+func TestDedupeStore(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "wecom.db")
+	store := &DedupeStore{DB: openTestDB(t, fn)}
+	require.NoError(t, store.Migrate())
+	ctx := context.Background()
+
+	// replicas
+	var added int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		replica := &DedupeStore{DB: openTestDB(t, fn)}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			state, err := replica.Add(ctx, "a", time.Hour)
+			assert.NoError(t, err)
+			if state == callback.DedupeAdded {
+				atomic.AddInt32(&added, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), added)
+
+	state, err := store.Add(ctx, "a", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, callback.DedupeProcessing, state)
+	assert.NoError(t, store.Done(ctx, "a", time.Hour))
+	state, err = store.Add(ctx, "a", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, callback.DedupeDone, state)
+	assert.NoError(t, store.Remove(ctx, "a"))
+	state, err = store.Add(ctx, "a", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, callback.DedupeAdded, state)
+
+	// expired key is taken over
+	assert.NoError(t, store.Done(ctx, "a", -time.Second))
+	state, err = store.Add(ctx, "a", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, callback.DedupeAdded, state)
+	state, err = store.Add(ctx, "a", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, callback.DedupeProcessing, state)
+
+	_, err = store.Add(ctx, "c", -time.Second)
+	assert.NoError(t, err)
+	n, err := store.Purge(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
@@ -1,14 +1,60 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/fish0607/go-wecom/commons/gorms"
+	"github.com/fish0607/go-wecom/wecom"
+	"github.com/oklog/ulid/v2"
+	"gorm.io/gorm"
 )
 
+// Token persisted wecom.GenericToken, unique by Type and OwnerID
 type Token struct {
 	gorms.Model
-	Type      string
+	Type      string `gorm:"uniqueIndex:tokens_type_owner_id"`
 	CorpID    string
+	OwnerID   string `gorm:"uniqueIndex:tokens_type_owner_id"`
+	Depends   string
+	Secret    string
+	ExpiresIn int
+	ExpiresAt int64
 	ExpiredAt *time.Time
 }
+
+// BeforeCreate generate the ID in lower case ulid, so the gen_ulid default of gorms.Model is not called
+func (t *Token) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = strings.ToLower(ulid.Make().String())
+	}
+	return nil
+}
+
+// GenericToken of Token, nil if no secret
+func (t *Token) GenericToken() *wecom.GenericToken {
+	if t.Secret == "" {
+		return nil
+	}
+	return &wecom.GenericToken{
+		Type:      t.Type,
+		OwnerID:   t.OwnerID,
+		Depends:   t.Depends,
+		Secret:    t.Secret,
+		ExpiresIn: t.ExpiresIn,
+		ExpiresAt: t.ExpiresAt,
+	}
+}
+
+// SetGenericToken update Token from in, keep Type and OwnerID
+func (t *Token) SetGenericToken(in *wecom.GenericToken) {
+	t.Depends = in.Depends
+	t.Secret = in.Secret
+	t.ExpiresIn = in.ExpiresIn
+	t.ExpiresAt = in.ExpiresAt
+	t.ExpiredAt = nil
+	if in.ExpiresAt > 0 {
+		at := time.Unix(in.ExpiresAt, 0)
+		t.ExpiredAt = &at
+	}
+}
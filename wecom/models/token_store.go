@@ -0,0 +1,102 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/fish0607/go-wecom/wecom"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TokenStore wecom.TokenLoadStore backed by the Token table, replicas share the same token.
+// The row is locked during Load, only one replica refresh the token at a time.
+//
+//	store := &models.TokenStore{DB: db}
+//	if err := store.Migrate(); err != nil {
+//		panic(err)
+//	}
+//	client := wecom.NewClient(wecom.Conf{TokenProvider: &wecom.TokenCache{Store: store}})
+type TokenStore struct {
+	DB *gorm.DB
+}
+
+// Migrate the Token table, the ID column of gorms.Model defaults to gen_ulid(), define the function first,
+// e.g. register it as a scalar function for sqlite like wwfinance-poller
+func (s *TokenStore) Migrate() error {
+	return s.DB.AutoMigrate(&Token{})
+}
+
+// Load implements wecom.TokenLoadStore
+func (s *TokenStore) Load(out *wecom.GenericToken, load func(last *wecom.GenericToken) (out *wecom.GenericToken, changed bool, err error)) (changed bool, err error) {
+	if out.Type == "" {
+		return false, errors.New("token need type")
+	}
+	var data *wecom.GenericToken
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		row, err := lockToken(tx, out.Type, out.OwnerID)
+		if err != nil {
+			return err
+		}
+		data = row.GenericToken()
+
+		o, c, err := load(data)
+		if err != nil || !c {
+			return err
+		}
+		changed, data = true, o
+		row.SetGenericToken(o)
+		return tx.Save(row).Error
+	})
+	if err == nil && data != nil {
+		*out = *data
+	}
+	return changed, err
+}
+
+// Get the stored token without lock
+func (s *TokenStore) Get(out *wecom.GenericToken) (found bool, err error) {
+	var row Token
+	err = s.DB.Where(map[string]interface{}{"type": out.Type, "owner_id": out.OwnerID}).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && row.Secret == "") {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	*out = *row.GenericToken()
+	return true, nil
+}
+
+// OwnerIDs implements wecom.TokenOwnerLister, only tokens with secret are listed
+func (s *TokenStore) OwnerIDs(typ wecom.TokenType, ownerPrefix string) ([]string, error) {
+	var ids []string
+	err := s.DB.Model(&Token{}).Where("type = ? AND secret <> ''", typ).Pluck("owner_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	out := ids[:0]
+	for _, v := range ids {
+		if strings.HasPrefix(v, ownerPrefix) {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// lockToken create the row if not exists, then select for update.
+// SQLite has no row lock, the insert hold the database write lock until commit.
+func lockToken(tx *gorm.DB, typ string, ownerID string) (*Token, error) {
+	row := &Token{Type: typ, OwnerID: ownerID}
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(row).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "create token")
+	}
+	row = &Token{}
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(map[string]interface{}{"type": typ, "owner_id": ownerID}).
+		Take(row).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "lock token")
+	}
+	return row, nil
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"database/sql/driver"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fish0607/go-wecom/wecom"
+	gsqlite "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var registerULID sync.Once
+
+func openTestDB(t *testing.T, fn string) *gorm.DB {
+	// default of gorms.Model.ID
+	registerULID.Do(func() {
+		gsqlite.MustRegisterScalarFunction("gen_ulid", 0, func(ctx *gsqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			return strings.ToLower(ulid.Make().String()), nil
+		})
+	})
+	db, err := gorm.Open(sqlite.Open(fn+"?_pragma=busy_timeout(10000)"), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	require.NoError(t, err)
+	return db
+}
+
+func TestTokenStore(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "wecom.db")
+	store := &TokenStore{DB: openTestDB(t, fn)}
+	require.NoError(t, store.Migrate())
+
+	// replicas
+	var refreshed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		cache := &wecom.TokenCache{Store: &TokenStore{DB: openTestDB(t, fn)}}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := cache.Refresh(&wecom.GenericToken{Type: "AccessToken", OwnerID: "corp"}, func() (wecom.OpaqueToken, error) {
+				n := atomic.AddInt32(&refreshed, 1)
+				return wecom.TokenResponse{AccessToken: "token-" + strconv.Itoa(int(n)), ExpiresIn: 7200}, nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "token-1", token)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), refreshed)
+
+	token := &wecom.GenericToken{Type: "AccessToken", OwnerID: "corp"}
+	found, err := store.Get(token)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "token-1", token.Secret)
+	assert.Equal(t, 7200, token.ExpiresIn)
+
+	var row Token
+	assert.NoError(t, store.DB.Take(&row).Error)
+	assert.NotEmpty(t, row.ID)
+	assert.Equal(t, token.ExpiresAt, row.ExpiredAt.Unix())
+
+	// other owner
+	found, err = store.Get(&wecom.GenericToken{Type: "AccessToken", OwnerID: "other"})
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	cache := &wecom.TokenCache{Store: store}
+	assert.NoError(t, cache.Invalidate(&wecom.GenericToken{Type: "AccessToken", OwnerID: "corp", Secret: "token-0"}))
+	found, _ = store.Get(&wecom.GenericToken{Type: "AccessToken", OwnerID: "corp"})
+	assert.True(t, found)
+	assert.NoError(t, cache.Invalidate(&wecom.GenericToken{Type: "AccessToken", OwnerID: "corp", Secret: "token-1"}))
+	found, _ = store.Get(&wecom.GenericToken{Type: "AccessToken", OwnerID: "corp"})
+	assert.False(t, found)
+
+	// tokens of auth corps of the suite
+	for _, id := range []string{"suite.corp1", "suite.corp2", "other.corp1"} {
+		_, err = cache.Refresh(&wecom.GenericToken{Type: "AuthCorpAccessToken", OwnerID: id}, func() (wecom.OpaqueToken, error) {
+			return wecom.TokenResponse{AccessToken: "token", ExpiresIn: 7200}, nil
+		})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, cache.InvalidatePrefix("AuthCorpAccessToken", "suite."))
+	ids, err := store.OwnerIDs("AuthCorpAccessToken", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"other.corp1"}, ids)
+
+	_, err = store.Load(&wecom.GenericToken{}, nil)
+	assert.Error(t, err)
+}
+
+func TestTokenStoreSuiteTicket(t *testing.T) {
+	store := &TokenStore{DB: openTestDB(t, filepath.Join(t.TempDir(), "wecom.db"))}
+	require.NoError(t, store.Migrate())
+	tickets := &wecom.TokenSuiteTicketStore{Store: store}
+
+	// missing ticket is read without creating the row
+	ticket, err := tickets.SuiteTicket("SuiteID")
+	assert.NoError(t, err)
+	assert.Empty(t, ticket)
+	var rows int64
+	assert.NoError(t, store.DB.Model(&Token{}).Count(&rows).Error)
+	assert.Equal(t, int64(0), rows)
+
+	assert.NoError(t, tickets.SetSuiteTicket("SuiteID", "ticket", 0))
+	ticket, err = tickets.SuiteTicket("SuiteID")
+	assert.NoError(t, err)
+	assert.Equal(t, "ticket", ticket)
+}
@@ -0,0 +1,322 @@
+package wecom
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CallInfo describe an api call made by Client
+type CallInfo struct {
+	Method     string
+	Path       string // url path, e.g. /cgi-bin/user/get
+	URL        string // url with secrets redacted
+	CorpID     string
+	AgentID    int
+	SuiteID    string
+	AuthCorpID string
+}
+
+// CallResult of an api call, include all retries
+type CallResult struct {
+	StatusCode int
+	ErrorCode  int // errcode of response
+	Error      error
+	Duration   time.Duration
+	Retries    int // replay times by token refresh or backoff
+}
+
+// Observer observe api calls of Client, e.g. tracing, metrics
+type Observer interface {
+	// StartCall is called before the call, the returned func is called when the call is finished
+	StartCall(ctx context.Context, info *CallInfo) (context.Context, func(result *CallResult))
+}
+
+// ObserverFunc implements Observer
+type ObserverFunc func(ctx context.Context, info *CallInfo) (context.Context, func(result *CallResult))
+
+// StartCall implements Observer
+func (f ObserverFunc) StartCall(ctx context.Context, info *CallInfo) (context.Context, func(result *CallResult)) {
+	return f(ctx, info)
+}
+
+// Observers combine multi Observer
+func Observers(o ...Observer) Observer {
+	return ObserverFunc(func(ctx context.Context, info *CallInfo) (context.Context, func(result *CallResult)) {
+		ends := make([]func(result *CallResult), 0, len(o))
+		for _, v := range o {
+			var end func(result *CallResult)
+			ctx, end = v.StartCall(ctx, info)
+			ends = append(ends, end)
+		}
+		return ctx, func(result *CallResult) {
+			for i := len(ends) - 1; i >= 0; i-- {
+				if ends[i] != nil {
+					ends[i](result)
+				}
+			}
+		}
+	})
+}
+
+var redactQueryKeys = []string{
+	"access_token",
+	"suite_access_token",
+	"provider_access_token",
+	"corpsecret",
+	"provider_secret",
+	"suite_secret",
+	"permanent_code",
+	"key",
+}
+
+// RedactURL replace tokens and secrets in query with REDACTED
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	q := u.Query()
+	redacted := false
+	for _, k := range redactQueryKeys {
+		if q.Has(k) {
+			q.Set(k, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.String()
+	}
+	c := *u
+	c.RawQuery = q.Encode()
+	return c.String()
+}
+
+func newCallInfo(c *Client, r *http.Request) *CallInfo {
+	return &CallInfo{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		URL:        RedactURL(r.URL),
+		CorpID:     c.Conf.CorpID,
+		AgentID:    c.Conf.AgentID,
+		SuiteID:    c.Conf.SuiteID,
+		AuthCorpID: c.Conf.AuthCorpID,
+	}
+}
+
+// observeRoundTrip notify Client.Observer around the whole call
+func observeRoundTrip(next http.RoundTripper, r *http.Request) (*http.Response, error) {
+	client := FromContext(r.Context())
+	if client == nil || (client.Observer == nil && client.Logger == nil) {
+		return roundTrip(next, r)
+	}
+	observer := client.Observer
+	switch {
+	case observer == nil:
+		observer = LoggingObserver{Logger: client.Logger}
+	case client.Logger != nil:
+		observer = Observers(observer, LoggingObserver{Logger: client.Logger})
+	}
+	ctx, end := observer.StartCall(r.Context(), newCallInfo(client, r))
+	r = r.WithContext(ctx)
+
+	attempts := 0
+	counter := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		return next.RoundTrip(r)
+	})
+	start := time.Now()
+	res, err := roundTrip(counter, r)
+	result := &CallResult{
+		Error:    err,
+		Duration: time.Since(start),
+	}
+	if attempts > 1 {
+		result.Retries = attempts - 1
+	}
+	if err == nil && res != nil {
+		var er GenericResponse
+		result.StatusCode = res.StatusCode
+		er, result.Error = peekGenericResponse(res)
+		result.ErrorCode = er.ErrorCode
+		if result.Error == nil {
+			result.Error = er.AsError()
+		}
+	}
+	if end != nil {
+		end(result)
+	}
+	return res, err
+}
+
+// TracingObserver adapt Observer to tracing system, e.g. OpenTelemetry
+//
+//	wecom.TracingObserver{
+//		Start: func(ctx context.Context, name string, attrs map[string]string) (context.Context, func(attrs map[string]string, err error)) {
+//			ctx, span := tracer.Start(ctx, name)
+//			// set attrs to span
+//			return ctx, func(attrs map[string]string, err error) {
+//				// set attrs, record err
+//				span.End()
+//			}
+//		},
+//	}
+type TracingObserver struct {
+	Start func(ctx context.Context, name string, attrs map[string]string) (context.Context, func(attrs map[string]string, err error))
+}
+
+// StartCall implements Observer
+func (o TracingObserver) StartCall(ctx context.Context, info *CallInfo) (context.Context, func(result *CallResult)) {
+	if o.Start == nil {
+		return ctx, nil
+	}
+	attrs := map[string]string{
+		"http.method":        info.Method,
+		"http.url":           info.URL,
+		"wecom.path":         info.Path,
+		"wecom.corp_id":      info.CorpID,
+		"wecom.agent_id":     strconv.Itoa(info.AgentID),
+		"wecom.suite_id":     info.SuiteID,
+		"wecom.auth_corp_id": info.AuthCorpID,
+	}
+	ctx, end := o.Start(ctx, "wecom "+info.Path, attrs)
+	return ctx, func(result *CallResult) {
+		if end == nil {
+			return
+		}
+		end(map[string]string{
+			"http.status_code": strconv.Itoa(result.StatusCode),
+			"wecom.errcode":    strconv.Itoa(result.ErrorCode),
+			"wecom.retries":    strconv.Itoa(result.Retries),
+		}, result.Error)
+	}
+}
+
+// DefaultDurationBuckets of MetricsObserver in seconds
+var DefaultDurationBuckets = []float64{.05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// MetricsObserver collect Prometheus style metrics of api calls, serve the metrics in text exposition format
+//
+//	wecom_api_requests_total{path,corp_id,agent_id,errcode}
+//	wecom_api_retries_total{path,corp_id,agent_id}
+//	wecom_api_request_duration_seconds{path}
+type MetricsObserver struct {
+	Buckets []float64 // default DefaultDurationBuckets
+
+	mu        sync.Mutex
+	requests  map[string]float64
+	retries   map[string]float64
+	durations map[string]*durationHistogram
+}
+
+type durationHistogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// StartCall implements Observer
+func (m *MetricsObserver) StartCall(ctx context.Context, info *CallInfo) (context.Context, func(result *CallResult)) {
+	return ctx, func(result *CallResult) {
+		m.observe(info, result)
+	}
+}
+
+func (m *MetricsObserver) observe(info *CallInfo, result *CallResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.requests == nil {
+		m.requests = map[string]float64{}
+		m.retries = map[string]float64{}
+		m.durations = map[string]*durationHistogram{}
+	}
+	buckets := m.buckets()
+	labels := fmt.Sprintf(`path=%q,corp_id=%q,agent_id="%d"`, info.Path, info.CorpID, info.AgentID)
+	errcode := strconv.Itoa(result.ErrorCode)
+	if result.Error != nil && result.StatusCode == 0 {
+		// request not sent or no response
+		errcode = "error"
+	}
+	m.requests[fmt.Sprintf(`%v,errcode=%q`, labels, errcode)]++
+	if result.Retries > 0 {
+		m.retries[labels] += float64(result.Retries)
+	}
+
+	key := fmt.Sprintf(`path=%q`, info.Path)
+	h := m.durations[key]
+	if h == nil {
+		h = &durationHistogram{counts: make([]uint64, len(buckets))}
+		m.durations[key] = h
+	}
+	sec := result.Duration.Seconds()
+	for i, le := range buckets {
+		if sec <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += sec
+	h.count++
+}
+
+func (m *MetricsObserver) buckets() []float64 {
+	if len(m.Buckets) == 0 {
+		return DefaultDurationBuckets
+	}
+	return m.Buckets
+}
+
+// WriteTo write metrics in Prometheus text exposition format
+func (m *MetricsObserver) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sb := &strings.Builder{}
+	writeCounter := func(name, help string, values map[string]float64) {
+		fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, k := range sortedKeys(values) {
+			fmt.Fprintf(sb, "%s{%s} %v\n", name, k, values[k])
+		}
+	}
+	writeCounter("wecom_api_requests_total", "Total api calls by errcode.", m.requests)
+	writeCounter("wecom_api_retries_total", "Total replays of api calls.", m.retries)
+
+	name := "wecom_api_request_duration_seconds"
+	fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s histogram\n", name, "Duration of api calls include retries.", name)
+	keys := make([]string, 0, len(m.durations))
+	for k := range m.durations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buckets := m.buckets()
+	for _, k := range keys {
+		h := m.durations[k]
+		for i, le := range buckets {
+			fmt.Fprintf(sb, "%s_bucket{%s,le=\"%v\"} %d\n", name, k, le, h.counts[i])
+		}
+		fmt.Fprintf(sb, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, k, h.count)
+		fmt.Fprintf(sb, "%s_sum{%s} %v\n", name, k, h.sum)
+		fmt.Fprintf(sb, "%s_count{%s} %d\n", name, k, h.count)
+	}
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// ServeHTTP serve metrics for Prometheus scrape
+func (m *MetricsObserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
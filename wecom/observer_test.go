@@ -0,0 +1,99 @@
+package wecom
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactURL(t *testing.T) {
+	u, _ := url.Parse("https://qyapi.weixin.qq.com/cgi-bin/user/get?access_token=abc&userid=test")
+	assert.Equal(t, "https://qyapi.weixin.qq.com/cgi-bin/user/get?access_token=REDACTED&userid=test", RedactURL(u))
+	assert.Equal(t, "https://qyapi.weixin.qq.com/cgi-bin/user/get?access_token=abc&userid=test", u.String())
+	u, _ = url.Parse("https://qyapi.weixin.qq.com/cgi-bin/gettoken?corpid=id&corpsecret=secret")
+	assert.Equal(t, "https://qyapi.weixin.qq.com/cgi-bin/gettoken?corpid=id&corpsecret=REDACTED", RedactURL(u))
+}
+
+func TestObserver(t *testing.T) {
+	mux := chi.NewMux()
+	count := 0
+	mux.Get("/cgi-bin/gettoken", func(writer http.ResponseWriter, request *http.Request) {
+		render.JSON(writer, request, TokenResponse{AccessToken: "AccessToken", ExpiresIn: 7200})
+	})
+	mux.Get("/cgi-bin/getcallbackip", func(writer http.ResponseWriter, request *http.Request) {
+		count++
+		if count < 2 {
+			render.JSON(writer, request, GenericResponse{ErrorCode: 45033, ErrorMessage: "api concurrent out of limit"})
+			return
+		}
+		render.JSON(writer, request, IPListResponse{IPList: []string{"127.0.0.1"}})
+	})
+	mux.Get("/cgi-bin/get_api_domain_ip", func(writer http.ResponseWriter, request *http.Request) {
+		render.JSON(writer, request, GenericResponse{ErrorCode: 48002, ErrorMessage: "api forbidden"})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var spans []map[string]string
+	var spanErrors []error
+	metrics := &MetricsObserver{}
+	client := NewClient(Conf{
+		CorpID:     "CorpID",
+		AgentID:    1000002,
+		CorpSecret: "CorpSecret",
+		Backoff:    &Backoff{MaxRetries: 3, Delay: time.Millisecond},
+		Observer: Observers(metrics, TracingObserver{
+			Start: func(ctx context.Context, name string, attrs map[string]string) (context.Context, func(attrs map[string]string, err error)) {
+				span := map[string]string{"name": name}
+				for k, v := range attrs {
+					span[k] = v
+				}
+				return ctx, func(attrs map[string]string, err error) {
+					for k, v := range attrs {
+						span[k] = v
+					}
+					spans = append(spans, span)
+					spanErrors = append(spanErrors, err)
+				}
+			},
+		}),
+	})
+	client.Request.BaseURL = server.URL
+
+	_, err := client.GetCallbackIP()
+	assert.NoError(t, err)
+	_, err = client.GetAPIDomainIP()
+	assert.Error(t, err)
+
+	// gettoken, getcallbackip, get_api_domain_ip
+	assert.Len(t, spans, 3)
+	span := spans[1]
+	assert.Equal(t, "wecom /cgi-bin/getcallbackip", span["name"])
+	assert.Equal(t, "CorpID", span["wecom.corp_id"])
+	assert.Equal(t, "1000002", span["wecom.agent_id"])
+	assert.Equal(t, "1", span["wecom.retries"])
+	assert.Equal(t, "0", span["wecom.errcode"])
+	assert.Contains(t, span["http.url"], "access_token=REDACTED")
+	assert.NotContains(t, spans[0]["http.url"], "CorpSecret")
+	assert.NoError(t, spanErrors[1])
+	assert.Equal(t, "48002", spans[2]["wecom.errcode"])
+	assert.True(t, IsPermissionDenied(spanErrors[2]))
+
+	buf := &bytes.Buffer{}
+	_, err = metrics.WriteTo(buf)
+	assert.NoError(t, err)
+	out := buf.String()
+	assert.Contains(t, out, `wecom_api_requests_total{path="/cgi-bin/getcallbackip",corp_id="CorpID",agent_id="1000002",errcode="0"} 1`)
+	assert.Contains(t, out, `wecom_api_requests_total{path="/cgi-bin/get_api_domain_ip",corp_id="CorpID",agent_id="1000002",errcode="48002"} 1`)
+	assert.Contains(t, out, `wecom_api_retries_total{path="/cgi-bin/getcallbackip",corp_id="CorpID",agent_id="1000002"} 1`)
+	assert.Contains(t, out, `wecom_api_request_duration_seconds_count{path="/cgi-bin/getcallbackip"} 1`)
+	assert.NotContains(t, out, "AccessToken")
+}
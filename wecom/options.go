@@ -1,6 +1,10 @@
 package wecom
 
-import "github.com/wenerme/go-req"
+import (
+	"context"
+
+	"github.com/wenerme/go-req"
+)
 
 // WithoutAccessToken when request do not add query access_token
 func WithoutAccessToken(o *middlewareOptions) {
@@ -9,6 +13,7 @@ func WithoutAccessToken(o *middlewareOptions) {
 
 // WithSuiteAccessToken when request add query suite_access_token
 func WithSuiteAccessToken(o *middlewareOptions) {
+	o.TokenType = TokenTypeSuiteAccessToken
 	o.GetToken = func(c *Client, r *req.Request) (string, string, error) {
 		token, err := c.SuiteAccessToken()
 		return "suite_access_token", token, err
@@ -17,6 +22,7 @@ func WithSuiteAccessToken(o *middlewareOptions) {
 
 // WithProviderAccessToken when request add query provider_access_token
 func WithProviderAccessToken(o *middlewareOptions) {
+	o.TokenType = TokenTypeProviderAccessToken
 	o.GetToken = func(c *Client, r *req.Request) (string, string, error) {
 		token, err := c.ProviderAccessToken()
 		return "provider_access_token", token, err
@@ -30,3 +36,21 @@ func WithProviderAccessToken(o *middlewareOptions) {
 func Debug(o *middlewareOptions) {
 	o.Debug = true
 }
+
+// WithRequestContext send this request with ctx, keep the Client of current request
+//
+//	client.GetUser(&wecom.GetUserRequest{UserID: "id"}, wecom.WithRequestContext(ctx))
+func WithRequestContext(ctx context.Context) func(r *req.Request) {
+	return func(r *req.Request) {
+		neo := ctx
+		if r.Context != nil {
+			if o := middlewareOptionsFromContext(r.Context); o != nil {
+				neo = context.WithValue(neo, middlewareOptionsContextKey, o)
+			}
+			if c := FromContext(r.Context); c != nil {
+				neo = NewContext(neo, c)
+			}
+		}
+		r.Context = neo
+	}
+}
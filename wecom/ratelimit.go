@@ -0,0 +1,191 @@
+package wecom
+
+import (
+	"context"
+	mathrand "math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wenerme/go-req"
+)
+
+// RateLimiter block the request until it's allowed to send
+type RateLimiter interface {
+	Wait(r *http.Request) error
+}
+
+// Backoff retry the request exponentially when wecom reports frequency limit - 45009, 45011, 45033
+type Backoff struct {
+	MaxRetries int           // max retries, 0 means no retry
+	Delay      time.Duration // delay before first retry, doubled for every retry
+	MaxDelay   time.Duration // max delay of one retry, 0 means no limit
+}
+
+// DefaultBackoff suggested Conf.Backoff, not enabled by default - retry 3 times after about 1s, 2s, 4s
+var DefaultBackoff = &Backoff{
+	MaxRetries: 3,
+	Delay:      time.Second,
+	MaxDelay:   30 * time.Second,
+}
+
+// delay of n-th retry with jitter
+func (b *Backoff) delay(n int) time.Duration {
+	d := b.Delay << n
+	if b.MaxDelay > 0 && (d <= 0 || d > b.MaxDelay) {
+		d = b.MaxDelay
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(mathrand.Int63n(int64(d/2))) //nolint:gosec
+}
+
+// PathRateLimiter limit request rate by url path prefix, every corp or webhook key has its own quota
+//
+//	limiter := (&wecom.PathRateLimiter{}).
+//		Limit("/cgi-bin/externalcontact/get", 20, time.Minute).
+//		Limit("/cgi-bin/webhook/send", 20, time.Minute)
+type PathRateLimiter struct {
+	mu      sync.Mutex
+	rules   []pathRateLimitRule
+	buckets map[string]*tokenBucket
+}
+
+type pathRateLimitRule struct {
+	Prefix string
+	Limit  int
+	Per    time.Duration
+}
+
+// Limit request of path prefix to n times per duration, the longest matched prefix wins
+func (l *PathRateLimiter) Limit(prefix string, n int, per time.Duration) *PathRateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rules = append(l.rules, pathRateLimitRule{Prefix: prefix, Limit: n, Per: per})
+	sort.SliceStable(l.rules, func(i, j int) bool {
+		return len(l.rules[i].Prefix) > len(l.rules[j].Prefix)
+	})
+	l.buckets = nil
+	return l
+}
+
+// Wait implements RateLimiter
+func (l *PathRateLimiter) Wait(r *http.Request) error {
+	d := l.reserve(r.URL.Path, rateLimitOwner(r))
+	return sleepContext(r.Context(), d)
+}
+
+func (l *PathRateLimiter) reserve(path string, owner string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, rule := range l.rules {
+		if !strings.HasPrefix(path, rule.Prefix) || rule.Limit <= 0 || rule.Per <= 0 {
+			continue
+		}
+		key := rule.Prefix + "|" + owner
+		if l.buckets == nil {
+			l.buckets = map[string]*tokenBucket{}
+		}
+		b := l.buckets[key]
+		if b == nil {
+			b = &tokenBucket{
+				rate:   float64(rule.Limit) / rule.Per.Seconds(),
+				burst:  float64(rule.Limit),
+				tokens: float64(rule.Limit),
+				last:   timeNow(),
+			}
+			l.buckets[key] = b
+		}
+		return b.reserve(timeNow())
+	}
+	return 0
+}
+
+// rateLimitOwner quota owner of request - corp of Client or key of webhook
+func rateLimitOwner(r *http.Request) string {
+	if c := FromContext(r.Context()); c != nil {
+		return joinIds(c.Conf.CorpID, c.Conf.AuthCorpID)
+	}
+	return r.URL.Query().Get("key")
+}
+
+type tokenBucket struct {
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve a token, return the duration to wait before use it
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// rateLimitTransport wait for RateLimiter before every attempt, retry by Backoff when frequency limited
+func rateLimitTransport(next http.RoundTripper, l RateLimiter, b *Backoff) http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		for n := 0; ; n++ {
+			if l != nil {
+				if err := l.Wait(r); err != nil {
+					return nil, err
+				}
+			}
+			res, err := next.RoundTrip(r)
+			if err != nil || b == nil || n >= b.MaxRetries {
+				return res, err
+			}
+			er, err := peekGenericResponse(res)
+			if err != nil || !isRateLimitErrorCode(er.ErrorCode) {
+				return res, err
+			}
+			if err = sleepContext(r.Context(), b.delay(n)); err != nil {
+				_ = res.Body.Close()
+				return nil, err
+			}
+			neo, err := cloneRequest(r)
+			if err != nil {
+				return res, nil
+			}
+			_ = res.Body.Close()
+			r = neo
+		}
+	})
+}
+
+// RateLimitHook apply RateLimiter and Backoff to request not made by Client, e.g. WebhookSendRequest.Request
+func RateLimitHook(l RateLimiter, b *Backoff) req.Hook {
+	return req.Hook{
+		Name: "WecomRateLimit",
+		HandleRequest: func(next http.RoundTripper) http.RoundTripper {
+			return rateLimitTransport(next, l, b)
+		},
+	}
+}
@@ -0,0 +1,73 @@
+package wecom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathRateLimiter(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	l := (&PathRateLimiter{}).
+		Limit("/cgi-bin/", 100, time.Minute).
+		Limit("/cgi-bin/webhook/send", 2, time.Minute)
+	assert.Equal(t, time.Duration(0), l.reserve("/cgi-bin/webhook/send", "A"))
+	assert.Equal(t, time.Duration(0), l.reserve("/cgi-bin/webhook/send", "A"))
+	assert.Equal(t, 30*time.Second, l.reserve("/cgi-bin/webhook/send", "A"))
+	// other owner
+	assert.Equal(t, time.Duration(0), l.reserve("/cgi-bin/webhook/send", "B"))
+	// other rule
+	assert.Equal(t, time.Duration(0), l.reserve("/cgi-bin/user/get", "A"))
+	// no rule
+	assert.Equal(t, time.Duration(0), l.reserve("/other", "A"))
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, time.Duration(0), l.reserve("/cgi-bin/webhook/send", "A"))
+}
+
+func TestBackoff(t *testing.T) {
+	mux := chi.NewMux()
+	count := 0
+	mux.Get("/cgi-bin/getcallbackip", func(writer http.ResponseWriter, request *http.Request) {
+		count++
+		if count < 3 {
+			render.JSON(writer, request, GenericResponse{ErrorCode: 45033, ErrorMessage: "api concurrent out of limit"})
+			return
+		}
+		render.JSON(writer, request, IPListResponse{IPList: []string{"127.0.0.1"}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(Conf{
+		Backoff: &Backoff{MaxRetries: 3, Delay: time.Millisecond},
+	})
+	client.Request.BaseURL = server.URL
+	client.Request.Options = append(client.Request.Options, WithoutAccessToken)
+
+	_, err := client.GetCallbackIP()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	count = 0
+	client.Backoff = &Backoff{MaxRetries: 1, Delay: time.Millisecond}
+	_, err = client.GetCallbackIP()
+	assert.EqualError(t, err, GenericResponse{ErrorCode: 45033, ErrorMessage: "api concurrent out of limit"}.Error())
+	assert.Equal(t, 2, count)
+
+	assert.Equal(t, time.Duration(0), (&Backoff{}).delay(0))
+	d := DefaultBackoff.delay(10)
+	assert.True(t, d >= DefaultBackoff.MaxDelay/2 && d <= DefaultBackoff.MaxDelay)
+
+	// fail fast by default
+	client = NewClient(Conf{})
+	assert.Nil(t, client.Backoff)
+}
@@ -16,6 +16,8 @@ type middlewareOptions struct {
 	WithoutAccessToken bool
 	Debug              bool
 	GetToken           func(c *Client, r *req.Request) (string, string, error)
+	TokenType          TokenType // type of token used by GetToken, default to TokenTypeAccessToken
+	TokenKey           string    // query key of token added by middleware, used to replay the request
 }
 
 const middlewareOptionsContextKey = contextKey("middlewareOptionsContextKey")
@@ -35,6 +37,11 @@ func getMiddlewareOptions(r *req.Request) *middlewareOptions {
 	return o
 }
 
+func middlewareOptionsFromContext(ctx context.Context) *middlewareOptions {
+	o, _ := ctx.Value(middlewareOptionsContextKey).(*middlewareOptions)
+	return o
+}
+
 func getAccessToken(c *Client, r *req.Request) (key string, token string, err error) {
 	key = "access_token"
 	token, err = c.AccessToken()
@@ -53,6 +60,9 @@ func requestMiddleware(r *req.Request) (err error) {
 	if o.GetToken == nil {
 		o.GetToken = getAccessToken
 	}
+	if o.TokenType == "" {
+		o.TokenType = TokenTypeAccessToken
+	}
 	var v url.Values
 	v, err = req.ValuesOf(r.Query)
 	if err != nil {
@@ -71,6 +81,7 @@ func requestMiddleware(r *req.Request) (err error) {
 		if err == nil {
 			if _, found := v[key]; !found {
 				v.Set(key, val)
+				o.TokenKey = key
 			}
 		}
 	}
@@ -88,13 +99,16 @@ var wecomeMiddleware = req.Hook{
 		}
 		return true, nil
 	},
+	HandleRequest: func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			return observeRoundTrip(next, r)
+		})
+	},
 	OnResponse: func(r *http.Response) error {
-		// todo detect json
-		body, err := io.ReadAll(r.Body)
+		body, err := peekBody(r)
 		if err != nil {
 			return err
 		}
-		r.Body = io.NopCloser(bytes.NewBuffer(body))
 		er := GenericResponse{}
 		// skip for now
 		if err = json.Unmarshal(body, &er); err != nil {
@@ -108,3 +122,87 @@ var wecomeMiddleware = req.Hook{
 		return er.AsError()
 	},
 }
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// peekBody read the body and keep it readable for next handler
+func peekBody(r *http.Response) ([]byte, error) {
+	// todo detect json
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewBuffer(body))
+	return body, nil
+}
+
+// peekGenericResponse return errcode and errmsg of json response, empty for non json response
+func peekGenericResponse(r *http.Response) (er GenericResponse, err error) {
+	body, err := peekBody(r)
+	if err == nil {
+		_ = json.Unmarshal(body, &er)
+	}
+	return
+}
+
+// cloneRequest for replay, the body is recreated by GetBody
+func cloneRequest(r *http.Request) (*http.Request, error) {
+	neo := r.Clone(r.Context())
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		neo.Body = body
+	}
+	return neo, nil
+}
+
+// roundTrip replay the request once with a fresh token when the token added by middleware is rejected
+func roundTrip(next http.RoundTripper, r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+	o := middlewareOptionsFromContext(ctx)
+	client := FromContext(ctx)
+	rr := req.FromContext(ctx)
+	if client != nil && (client.RateLimiter != nil || client.Backoff != nil) {
+		next = rateLimitTransport(next, client.RateLimiter, client.Backoff)
+	}
+
+	res, err := next.RoundTrip(r)
+	if err != nil {
+		return res, err
+	}
+	if o == nil || o.WithoutAccessToken || o.TokenKey == "" || client == nil || rr == nil {
+		return res, nil
+	}
+	er, err := peekGenericResponse(res)
+	if err != nil || !isTokenErrorCode(er.ErrorCode) {
+		return res, err
+	}
+
+	rejected := r.URL.Query().Get(o.TokenKey)
+	if err = client.invalidateToken(o.TokenType, rejected); err != nil {
+		// can not get a different token
+		return res, nil
+	}
+	key, token, err := o.GetToken(client, rr)
+	if err != nil {
+		_ = res.Body.Close()
+		return nil, errors.Wrapf(err, "refresh rejected %v", key)
+	}
+
+	neo, err := cloneRequest(r)
+	if err != nil {
+		return res, nil
+	}
+	q := neo.URL.Query()
+	q.Set(key, token)
+	neo.URL.RawQuery = q.Encode()
+	_ = res.Body.Close()
+	return next.RoundTrip(neo)
+}
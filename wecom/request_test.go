@@ -0,0 +1,156 @@
+package wecom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryOnTokenError(t *testing.T) {
+	mux := chi.NewMux()
+	tokens := []string{"AccessToken" + createNonce(), "AccessToken" + createNonce()}
+	getTokenCount := 0
+	mux.Get("/cgi-bin/gettoken", func(writer http.ResponseWriter, request *http.Request) {
+		render.JSON(writer, request, TokenResponse{AccessToken: tokens[getTokenCount%len(tokens)], ExpiresIn: 7200})
+		getTokenCount++
+	})
+	mockIPList := IPListResponse{IPList: []string{"127.0.0.1"}}
+	mux.Get("/cgi-bin/getcallbackip", func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Query().Get("access_token") {
+		case tokens[0]:
+			render.JSON(writer, request, GenericResponse{ErrorCode: 40014, ErrorMessage: "invalid access_token"})
+		case tokens[1]:
+			render.JSON(writer, request, mockIPList)
+		default:
+			render.JSON(writer, request, GenericResponse{ErrorCode: 42001, ErrorMessage: "access_token expired"})
+		}
+	})
+	mux.Post("/cgi-bin/user/batchdelete", func(writer http.ResponseWriter, request *http.Request) {
+		r := BatchDeleteUserRequest{}
+		assert.NoError(t, render.Decode(request, &r))
+		assert.Equal(t, []string{"test"}, r.UserIDList)
+		render.JSON(writer, request, GenericResponse{ErrorCode: 40014, ErrorMessage: "invalid access_token"})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(Conf{
+		CorpID:     "CorpID",
+		CorpSecret: "CorpSecret",
+	})
+	client.Request.BaseURL = server.URL
+
+	{
+		r, err := client.GetCallbackIP()
+		assert.NoError(t, err)
+		assert.Equal(t, mockIPList, r)
+		assert.Equal(t, 2, getTokenCount)
+
+		token, err := client.AccessToken()
+		assert.NoError(t, err)
+		assert.Equal(t, tokens[1], token)
+	}
+	{
+		// replay once only
+		_, err := client.BatchDeleteUser(&BatchDeleteUserRequest{UserIDList: []string{"test"}})
+		assert.EqualError(t, err, GenericResponse{ErrorCode: 40014, ErrorMessage: "invalid access_token"}.Error())
+		assert.Equal(t, 3, getTokenCount)
+	}
+}
+
+func TestTokenCacheInvalidate(t *testing.T) {
+	store := &SyncMapStore{}
+	tc := &TokenCache{Store: store}
+	assert.NoError(t, store.Set(&GenericToken{Type: "A", Secret: "1"}))
+
+	// not the cached one
+	assert.NoError(t, tc.Invalidate(&GenericToken{Type: "A", Secret: "2"}))
+	found, err := store.Get(&GenericToken{Type: "A"})
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	assert.NoError(t, tc.Invalidate(&GenericToken{Type: "A", Secret: "1"}))
+	found, err = store.Get(&GenericToken{Type: "A"})
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestInvalidateToken(t *testing.T) {
+	store := &SyncMapStore{}
+	client := NewClient(Conf{
+		CorpID:      "CorpID",
+		CorpSecret:  "CorpSecret",
+		SuiteID:     "SuiteID",
+		SuiteSecret: "SuiteSecret",
+		TokenProvider: &TokenCache{
+			Store: store,
+		},
+	})
+	auth := client.With(Conf{SuiteID: "SuiteID", SuiteSecret: "SuiteSecret", AuthCorpID: "AuthCorpID"})
+	auth2 := client.With(Conf{SuiteID: "SuiteID", SuiteSecret: "SuiteSecret", AuthCorpID: "AuthCorpID2"})
+	other := client.With(Conf{SuiteID: "OtherSuiteID", SuiteSecret: "SuiteSecret", AuthCorpID: "AuthCorpID"})
+	tokens := []*GenericToken{
+		client.tokenOf(TokenTypeAccessToken),
+		client.tokenOf(TokenTypeJsAPITicket),
+		client.tokenOf(TokenTypeAgentTicket),
+		client.tokenOf(TokenTypeProviderAccessToken),
+		client.tokenOf(TokenTypeSuiteAccessToken),
+		client.tokenOf(TokenTypeSuiteTicket),
+		auth.tokenOf(TokenTypeAuthCorpAccessToken),
+		auth.tokenOf(TokenTypeJsAPITicket),
+		auth2.tokenOf(TokenTypeAuthCorpAccessToken),
+		auth2.tokenOf(TokenTypeAgentTicket),
+		other.tokenOf(TokenTypeAuthCorpAccessToken),
+	}
+	cached := func() (out []string) {
+		for _, v := range tokens {
+			t := *v
+			if found, _ := store.Get(&t); found {
+				out = append(out, t.Type+"."+t.OwnerID)
+			}
+		}
+		return
+	}
+	for _, v := range tokens {
+		v.Secret = "secret"
+		assert.NoError(t, store.Set(v))
+	}
+
+	assert.NoError(t, client.InvalidateToken(TokenTypeAccessToken))
+	assert.Equal(t, []string{
+		"ProviderAccessToken.CorpID",
+		"SuiteAccessToken.SuiteID",
+		"SuiteTicket.SuiteID",
+		"AuthCorpAccessToken.SuiteID.AuthCorpID",
+		"JsAPITicket.SuiteID.AuthCorpID",
+		"AuthCorpAccessToken.SuiteID.AuthCorpID2",
+		"AgentTicket.SuiteID.AuthCorpID2",
+		"AuthCorpAccessToken.OtherSuiteID.AuthCorpID",
+	}, cached())
+
+	assert.NoError(t, auth2.InvalidateToken(TokenTypeAuthCorpAccessToken))
+	assert.Equal(t, []string{
+		"ProviderAccessToken.CorpID",
+		"SuiteAccessToken.SuiteID",
+		"SuiteTicket.SuiteID",
+		"AuthCorpAccessToken.SuiteID.AuthCorpID",
+		"JsAPITicket.SuiteID.AuthCorpID",
+		"AuthCorpAccessToken.OtherSuiteID.AuthCorpID",
+	}, cached())
+	assert.NoError(t, store.Set(&GenericToken{Type: TokenTypeAuthCorpAccessToken, OwnerID: "SuiteID.AuthCorpID2", Secret: "secret"}))
+
+	// suite client without AuthCorpID drop the tokens of all auth corps of the suite
+	assert.NoError(t, client.InvalidateToken(TokenTypeSuiteAccessToken))
+	assert.Equal(t, []string{
+		"ProviderAccessToken.CorpID",
+		"SuiteTicket.SuiteID",
+		"AuthCorpAccessToken.OtherSuiteID.AuthCorpID",
+	}, cached())
+
+	client.TokenProvider = struct{ TokenProvider }{}
+	assert.Error(t, client.InvalidateToken(TokenTypeAccessToken))
+}
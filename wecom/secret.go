@@ -2,7 +2,8 @@ package wecom
 
 import (
 	"encoding/json"
-	"log"
+	"math"
+	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
@@ -110,18 +111,21 @@ var timeNowUnix = func() int64 {
 	return timeNow().Unix()
 }
 
-// Refresh token, return changed and error
+// Refresh token, return changed and error, ignorable error is logged by DefaultLogger
 func (t *GenericToken) Refresh(exp *GenericToken, f func() (OpaqueToken, error)) (bool, error) {
+	return t.refresh(exp, f, DefaultLogger)
+}
+
+func (t *GenericToken) refresh(exp *GenericToken, f func() (OpaqueToken, error), logger Logger) (bool, error) {
 	var changed bool
 	if t.ShouldRefresh(exp) {
 		token, err := f()
-		// can keep using valid
+		// can keep using valid, unless it belongs to other owner or depends, e.g. rotated secret
 		switch {
-		case err != nil && !t.IsValid():
+		case err != nil && (!t.IsValid() || t.OwnerID != exp.OwnerID || t.Depends != exp.Depends):
 			return false, err
 		case err != nil:
-			// proper log error
-			log.Printf("Refresh token %v ignoreable error: %v", exp.Type, err.Error())
+			logger.Warn("refresh token failed, keep using the valid one", "type", exp.Type, "owner_id", exp.OwnerID, "error", err.Error())
 		case err == nil && token != nil:
 			t.SetFromToken(token)
 			t.Type = exp.Type
@@ -134,19 +138,36 @@ func (t *GenericToken) Refresh(exp *GenericToken, f func() (OpaqueToken, error))
 }
 
 func (t *GenericToken) ShouldRefresh(exp *GenericToken) bool {
+	// 80% expires_in
+	return t.shouldRefreshAt(exp, .8)
+}
+
+// shouldRefreshAt refresh when ratio of expires_in is elapsed
+func (t *GenericToken) shouldRefreshAt(exp *GenericToken, ratio float64) bool {
 	switch {
 	case t == nil || t.Secret == "":
 	case t.OwnerID != exp.OwnerID || t.Depends != exp.Depends:
-	case t.ExpiresIn > 0 && t.ExpiresAt-int64(.2*float64(t.ExpiresIn)) <= timeNow().Unix():
-		// 80% expires_in
+	case t.ExpiresIn > 0 && t.ExpiresAt-int64(math.Round((1-ratio)*float64(t.ExpiresIn))) <= timeNow().Unix():
 	default:
 		return false
 	}
 	return true
 }
 
+// TokenCache TokenProvider backed by TokenLoadStore, concurrent Refresh of the same token share one in-flight load,
+// so the token is fetched once no matter which store is used.
 type TokenCache struct {
-	Store TokenLoadStore
+	Store  TokenLoadStore
+	Logger Logger // default DefaultLogger
+
+	mu      sync.Mutex
+	flights map[string]*tokenFlight
+}
+
+type tokenFlight struct {
+	wg    sync.WaitGroup
+	token GenericToken
+	err   error
 }
 
 func keyOfGenericToken(t *GenericToken) (k string, err error) {
@@ -160,22 +181,137 @@ func keyOfGenericToken(t *GenericToken) (k string, err error) {
 	return
 }
 
+// ownerOfKey the OwnerID of key built by keyOfGenericToken, false if key is not of typ
+func ownerOfKey(key string, typ TokenType) (string, bool) {
+	if key == typ {
+		return "", true
+	}
+	if strings.HasPrefix(key, typ+".") {
+		return key[len(typ)+1:], true
+	}
+	return "", false
+}
+
 func (tc *TokenCache) Refresh(exp *GenericToken, f func() (OpaqueToken, error)) (string, error) {
+	token, err := tc.RefreshToken(exp, f)
+	if err != nil {
+		return "", err
+	}
+	return token.Secret, nil
+}
+
+// RefreshToken implements GenericTokenProvider
+func (tc *TokenCache) RefreshToken(exp *GenericToken, f func() (OpaqueToken, error)) (*GenericToken, error) {
+	return tc.do(exp, func() (GenericToken, error) {
+		return tc.load(exp, f)
+	})
+}
+
+// do run load once for concurrent calls of the same token and Depends, the others wait for the result
+func (tc *TokenCache) do(exp *GenericToken, load func() (GenericToken, error)) (*GenericToken, error) {
+	key, err := keyOfGenericToken(exp)
+	if err != nil {
+		return nil, err
+	}
+	key += "/" + exp.Depends
+
+	tc.mu.Lock()
+	if fl, ok := tc.flights[key]; ok {
+		tc.mu.Unlock()
+		fl.wg.Wait()
+		return fl.result()
+	}
+	if tc.flights == nil {
+		tc.flights = make(map[string]*tokenFlight)
+	}
+	fl := &tokenFlight{err: errors.Errorf("refresh %v aborted", key)} // waiters get this if load panics
+	fl.wg.Add(1)
+	tc.flights[key] = fl
+	tc.mu.Unlock()
+
+	defer func() {
+		tc.mu.Lock()
+		delete(tc.flights, key)
+		tc.mu.Unlock()
+		fl.wg.Done()
+	}()
+	fl.token, fl.err = load()
+	return fl.result()
+}
+
+func (fl *tokenFlight) result() (*GenericToken, error) {
+	if fl.err != nil {
+		return nil, fl.err
+	}
+	token := fl.token
+	return &token, nil
+}
+
+func (tc *TokenCache) load(exp *GenericToken, f func() (OpaqueToken, error)) (GenericToken, error) {
 	token := *exp
 	_, err := tc.Store.Load(&token, func(last *GenericToken) (out *GenericToken, changed bool, err error) {
 		if last == nil {
 			last = &GenericToken{}
 		}
-		changed, err = last.Refresh(exp, f)
+		changed, err = last.refresh(exp, f, loggerOr(tc.Logger))
 		return last, changed, err
 	})
-	return token.Secret, err
+	return token, err
+}
+
+// Invalidate drop the cached token of exp, if exp.Secret is set only drop when it's still the cached one
+func (tc *TokenCache) Invalidate(exp *GenericToken) error {
+	token := *exp
+	_, err := tc.Store.Load(&token, func(last *GenericToken) (*GenericToken, bool, error) {
+		if last == nil || last.Secret == "" || (exp.Secret != "" && last.Secret != exp.Secret) {
+			return last, false, nil
+		}
+		return &GenericToken{Type: exp.Type, OwnerID: exp.OwnerID}, true, nil
+	})
+	return err
+}
+
+// InvalidatePrefix drop the cached tokens of typ whose OwnerID has ownerPrefix, empty ownerPrefix for all tokens of typ,
+// Store must implement TokenOwnerLister
+func (tc *TokenCache) InvalidatePrefix(typ TokenType, ownerPrefix string) error {
+	lister, ok := tc.Store.(TokenOwnerLister)
+	if !ok {
+		return errors.Errorf("Store %T can not list tokens", tc.Store)
+	}
+	ids, err := lister.OwnerIDs(typ, ownerPrefix)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err = tc.Invalidate(&GenericToken{Type: typ, OwnerID: id}); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type TokenProvider interface {
 	Refresh(exp *GenericToken, f func() (OpaqueToken, error)) (string, error)
 }
 
+// GenericTokenProvider is implemented by TokenProvider which can return the token with expiry
+type GenericTokenProvider interface {
+	RefreshToken(exp *GenericToken, f func() (OpaqueToken, error)) (*GenericToken, error)
+}
+
+// TokenInvalidator is implemented by TokenProvider which can drop a rejected token
+type TokenInvalidator interface {
+	Invalidate(exp *GenericToken) error
+	// InvalidatePrefix drop all tokens of typ whose OwnerID has ownerPrefix, empty ownerPrefix for all tokens of typ
+	InvalidatePrefix(typ TokenType, ownerPrefix string) error
+}
+
+// TokenOwnerLister is implemented by TokenLoadStore which can list the cached tokens, used by TokenCache.InvalidatePrefix
+type TokenOwnerLister interface {
+	// OwnerIDs of the cached tokens of typ whose OwnerID has ownerPrefix
+	OwnerIDs(typ TokenType, ownerPrefix string) ([]string, error)
+}
+
 type TokenLoadStore interface {
 	Load(out *GenericToken, load func(last *GenericToken) (out *GenericToken, changed bool, err error)) (changed bool, err error)
 }
@@ -183,6 +319,7 @@ type TokenLoadStore interface {
 type SyncMapStore struct {
 	m        sync.Map
 	OnChange func(s *SyncMapStore)
+	Logger   Logger // default DefaultLogger
 }
 
 type cacheEntry struct {
@@ -220,7 +357,7 @@ func (s *SyncMapStore) Dump() []byte {
 	})
 	out, err := json.Marshal(a)
 	if err != nil {
-		log.Println("Marshal Dump failed:", err.Error())
+		loggerOr(s.Logger).Error("marshal token cache failed", "error", err.Error())
 	}
 	return out
 }
@@ -257,7 +394,11 @@ func (s *SyncMapStore) Load(out *GenericToken, loadFunc func(last *GenericToken)
 	o, c, err := loadFunc(data)
 	if err == nil && c {
 		data = o
-		s.m.Store(key, *o)
+		if o.Secret == "" {
+			s.m.Delete(key)
+		} else {
+			s.m.Store(key, *o)
+		}
 		if s.OnChange != nil {
 			s.OnChange(s)
 		}
@@ -281,3 +422,14 @@ func (s *SyncMapStore) Get(out *GenericToken) (found bool, err error) {
 	}
 	return
 }
+
+// OwnerIDs implements TokenOwnerLister
+func (s *SyncMapStore) OwnerIDs(typ TokenType, ownerPrefix string) (out []string, err error) {
+	s.m.Range(func(key, value interface{}) bool {
+		if id, ok := ownerOfKey(key.(string), typ); ok && strings.HasPrefix(id, ownerPrefix) {
+			out = append(out, id)
+		}
+		return true
+	})
+	return
+}
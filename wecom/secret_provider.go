@@ -0,0 +1,147 @@
+package wecom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// SecretName of the Conf secrets
+const (
+	SecretCorpSecret     = "CorpSecret"
+	SecretProviderSecret = "ProviderSecret"
+	SecretSuiteSecret    = "SuiteSecret"
+)
+
+// SecretProvider provide the secrets of Conf, the secret is re-read when it's rejected by wecom,
+// so a secret rotated in the console take effect without restart.
+type SecretProvider interface {
+	// Secret of name - SecretCorpSecret, SecretProviderSecret or SecretSuiteSecret, empty if not configured
+	Secret(name string) (string, error)
+}
+
+// SecretProviderFunc func as SecretProvider
+type SecretProviderFunc func(name string) (string, error)
+
+// Secret implements SecretProvider
+func (f SecretProviderFunc) Secret(name string) (string, error) {
+	return f(name)
+}
+
+// EnvSecretProvider read the secret from env every time, e.g. WECOM_CORP_SECRET or the file of WECOM_CORP_SECRET_FILE,
+// works with mounted secret files which are updated on rotation.
+type EnvSecretProvider struct {
+	Prefix string // default WECOM_
+}
+
+// Secret implements SecretProvider
+func (p EnvSecretProvider) Secret(name string) (string, error) {
+	prefix := p.Prefix
+	if prefix == "" {
+		prefix = "WECOM_"
+	}
+	switch name {
+	case SecretCorpSecret:
+		return lookupEnv(prefix + "CORP_SECRET")
+	case SecretProviderSecret:
+		return lookupEnv(prefix + "PROVIDER_SECRET")
+	case SecretSuiteSecret:
+		return lookupEnv(prefix + "SUITE_SECRET")
+	}
+	return "", errors.Errorf("unknown secret %v", name)
+}
+
+// secretOfConf the static secret of Conf
+func secretOfConf(c *Conf, name string) string {
+	switch name {
+	case SecretCorpSecret:
+		return c.CorpSecret
+	case SecretProviderSecret:
+		return c.ProviderSecret
+	case SecretSuiteSecret:
+		return c.SuiteSecret
+	}
+	return ""
+}
+
+// secretCache secrets loaded from Conf.SecretProvider, shared by the copies of Client
+type secretCache struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// secret of name, static secret of Conf take precedence over Conf.SecretProvider
+func (c *Client) secret(name string) (string, error) {
+	if v := secretOfConf(&c.Conf, name); v != "" || c.Conf.SecretProvider == nil {
+		return v, nil
+	}
+	if c.secrets == nil {
+		// zero Client, not created by NewClient - read the provider every time
+		v, err := c.Conf.SecretProvider.Secret(name)
+		return v, errors.Wrapf(err, "load %v", name)
+	}
+	c.secrets.mu.Lock()
+	v, ok := c.secrets.values[name]
+	c.secrets.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+	_, err := c.reloadSecret(name)
+	if err != nil {
+		return "", err
+	}
+	c.secrets.mu.Lock()
+	defer c.secrets.mu.Unlock()
+	return c.secrets.values[name], nil
+}
+
+// hasSecret the secret is configured
+func (c *Client) hasSecret(name string) bool {
+	v, err := c.secret(name)
+	return err == nil && v != ""
+}
+
+// reloadSecret read the secret from Conf.SecretProvider again
+func (c *Client) reloadSecret(name string) (changed bool, err error) {
+	if c.Conf.SecretProvider == nil || secretOfConf(&c.Conf, name) != "" || c.secrets == nil {
+		// nothing cached, the latest secret is always used
+		return false, nil
+	}
+	v, err := c.Conf.SecretProvider.Secret(name)
+	if err != nil {
+		return false, errors.Wrapf(err, "load %v", name)
+	}
+	c.secrets.mu.Lock()
+	defer c.secrets.mu.Unlock()
+	last, ok := c.secrets.values[name]
+	if c.secrets.values == nil {
+		c.secrets.values = make(map[string]string)
+	}
+	c.secrets.values[name] = v
+	return ok && last != v, nil
+}
+
+// secretFingerprint of secret, used as GenericToken.Depends, so tokens of the old secret are refreshed after rotation
+func (c *Client) secretFingerprint(name string) string {
+	v, err := c.secret(name)
+	if err != nil || v == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(sum[:8])
+}
+
+// refreshWithSecret refresh the token fetched by secret name, when the secret is rejected and changed in
+// Conf.SecretProvider, refresh again with the new secret
+func (c *Client) refreshWithSecret(typ TokenType, name string, f func(c *Client) (OpaqueToken, error)) (*GenericToken, error) {
+	t, err := c.refresh(c.tokenOf(typ), f)
+	if err == nil || !IsSecretError(err) {
+		return t, err
+	}
+	if changed, rerr := c.reloadSecret(name); rerr != nil || !changed {
+		return t, err
+	}
+	return c.refresh(c.tokenOf(typ), f)
+}
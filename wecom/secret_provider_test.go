@@ -0,0 +1,92 @@
+package wecom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretProvider(t *testing.T) {
+	ts := NewTestServer()
+	defer ts.Start()()
+
+	secret := ts.CorpSecret
+	loads := 0
+	store := &SyncMapStore{}
+	client := NewClient(Conf{
+		CorpID: ts.CorpID,
+		SecretProvider: SecretProviderFunc(func(name string) (string, error) {
+			if name != SecretCorpSecret {
+				return "", nil
+			}
+			loads++
+			return secret, nil
+		}),
+		TokenProvider: &TokenCache{Store: store},
+	})
+	client.Request.BaseURL = ts.URL()
+
+	_, err := client.GetUser(&GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	token, err := client.Token(TokenTypeAccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, ts.AccessToken, token.Secret)
+	assert.NotEmpty(t, token.Depends)
+	assert.Equal(t, 1, loads)
+
+	// rotated in console, old token revoked
+	secret = "rotated"
+	ts.CorpSecret = secret
+	ts.ExpireAccessToken()
+	_, err = client.GetUser(&GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, loads)
+	rotated, err := client.Token(TokenTypeAccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, ts.AccessToken, rotated.Secret)
+	assert.NotEqual(t, token.Depends, rotated.Depends)
+
+	// cached token of old secret is refreshed
+	ts.ExpireAccessToken()
+	old := NewClient(Conf{CorpID: ts.CorpID, CorpSecret: "old", TokenProvider: &TokenCache{Store: store}})
+	old.Request.BaseURL = ts.URL()
+	_, err = old.AccessToken()
+	assert.True(t, IsSecretError(err))
+
+	assert.NoError(t, client.Conf.Validate())
+
+	// zero Client without the cache
+	zero := &Client{Conf: Conf{SecretProvider: SecretProviderFunc(func(name string) (string, error) {
+		return secret, nil
+	})}}
+	v, err := zero.secret(SecretCorpSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, secret, v)
+	assert.True(t, zero.hasSecret(SecretCorpSecret))
+	assert.NotEmpty(t, zero.secretFingerprint(SecretCorpSecret))
+	changed, err := zero.reloadSecret(SecretCorpSecret)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestEnvSecretProvider(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "secret")
+	assert.NoError(t, os.WriteFile(fn, []byte("suite\n"), 0o600))
+	t.Setenv("TEST_CORP_SECRET", "corp")
+	t.Setenv("TEST_SUITE_SECRET_FILE", fn)
+
+	p := EnvSecretProvider{Prefix: "TEST_"}
+	v, err := p.Secret(SecretCorpSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, "corp", v)
+	v, err = p.Secret(SecretSuiteSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, "suite", v)
+	v, err = p.Secret(SecretProviderSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, "", v)
+	_, err = p.Secret("Unknown")
+	assert.Error(t, err)
+}
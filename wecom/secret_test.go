@@ -1,6 +1,9 @@
 package wecom
 
 import (
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -218,3 +221,42 @@ func TestTokenCache(t *testing.T) {
 		assert.Equal(t, "3", token)
 	}
 }
+
+func TestTokenCacheSingleFlight(t *testing.T) {
+	tc := &TokenCache{Store: &SyncMapStore{}}
+	var calls int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := tc.Refresh(&GenericToken{Type: "A", OwnerID: "corp"}, func() (OpaqueToken, error) {
+				n := atomic.AddInt32(&calls, 1)
+				time.Sleep(20 * time.Millisecond)
+				return &GenericToken{Secret: strconv.Itoa(int(n)), ExpiresIn: 7200}, nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "1", token)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls)
+
+	// shared error
+	var failed int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := tc.Refresh(&GenericToken{Type: "B"}, func() (OpaqueToken, error) {
+				atomic.AddInt32(&failed, 1)
+				time.Sleep(20 * time.Millisecond)
+				return nil, errors.New("failed")
+			})
+			assert.Error(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Less(t, failed, int32(10))
+	assert.Empty(t, tc.flights)
+}
@@ -0,0 +1,93 @@
+package wecom
+
+import (
+	"github.com/pkg/errors"
+)
+
+// SuiteTicketExpiresIn suite_ticket is valid for 30 minutes, pushed every 10 minutes
+const SuiteTicketExpiresIn = 1800
+
+// SuiteTicketStore store of the suite_ticket pushed to the callback, e.g. saved by callback.Handler.SuiteTickets
+type SuiteTicketStore interface {
+	// SuiteTicket the latest ticket of suiteID, empty if not found or expired
+	SuiteTicket(suiteID string) (string, error)
+	// SetSuiteTicket save the ticket pushed at timestamp, the ticket older than the saved one is ignored
+	SetSuiteTicket(suiteID string, ticket string, timestamp int64) error
+}
+
+// TokenSuiteTicketStore SuiteTicketStore saved as the SuiteTicket token of TokenLoadStore -
+// SyncMapStore in memory, FileStore or models.TokenStore for SQL.
+//
+//	store := &wecom.SyncMapStore{}
+//	client := wecom.NewClient(wecom.Conf{
+//		SuiteID:          "",
+//		SuiteSecret:      "",
+//		TokenProvider:    &wecom.TokenCache{Store: store},
+//		SuiteTicketStore: &wecom.TokenSuiteTicketStore{Store: store},
+//	})
+type TokenSuiteTicketStore struct {
+	Store TokenLoadStore
+}
+
+// SuiteTicket implements SuiteTicketStore, read by Get of the store without lock if implemented
+func (s *TokenSuiteTicketStore) SuiteTicket(suiteID string) (string, error) {
+	token := &GenericToken{Type: TokenTypeSuiteTicket, OwnerID: suiteID}
+	var err error
+	if g, ok := s.Store.(interface {
+		Get(out *GenericToken) (found bool, err error)
+	}); ok {
+		_, err = g.Get(token)
+	} else {
+		_, err = s.Store.Load(token, func(last *GenericToken) (*GenericToken, bool, error) {
+			return last, false, nil
+		})
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "load suite ticket")
+	}
+	if token.Secret == "" || (token.ExpiresAt > 0 && token.ExpiresAt <= timeNow().Unix()) {
+		return "", nil
+	}
+	return token.Secret, nil
+}
+
+// SetSuiteTicket implements SuiteTicketStore
+func (s *TokenSuiteTicketStore) SetSuiteTicket(suiteID string, ticket string, timestamp int64) error {
+	if suiteID == "" || ticket == "" {
+		return errors.New("missing SuiteID or SuiteTicket")
+	}
+	if timestamp <= 0 {
+		timestamp = timeNow().Unix()
+	}
+	next := &GenericToken{
+		Type:      TokenTypeSuiteTicket,
+		OwnerID:   suiteID,
+		Secret:    ticket,
+		ExpiresIn: SuiteTicketExpiresIn,
+		ExpiresAt: timestamp + SuiteTicketExpiresIn,
+	}
+	_, err := s.Store.Load(&GenericToken{Type: TokenTypeSuiteTicket, OwnerID: suiteID}, func(last *GenericToken) (*GenericToken, bool, error) {
+		if last != nil && last.Secret != "" && last.ExpiresAt >= next.ExpiresAt {
+			// retried or delayed push
+			return last, false, nil
+		}
+		return next, true, nil
+	})
+	return errors.Wrap(err, "save suite ticket")
+}
+
+// suiteTicket the latest ticket of Conf.SuiteTicketStore, then Conf.SuiteTicket and the SuiteTicket of TokenProvider
+func (c *Client) suiteTicket() (string, error) {
+	if s := c.Conf.SuiteTicketStore; s != nil {
+		ticket, err := s.SuiteTicket(c.Conf.SuiteID)
+		if err != nil || ticket != "" {
+			return ticket, err
+		}
+	}
+	if c.Conf.SuiteTicket != "" {
+		return c.Conf.SuiteTicket, nil
+	}
+	return c.TokenProvider.Refresh(c.tokenOf(TokenTypeSuiteTicket), func() (OpaqueToken, error) {
+		return nil, errors.New("missing suite ticket")
+	})
+}
@@ -0,0 +1,86 @@
+package wecom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenSuiteTicketStore(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	for _, store := range []TokenLoadStore{
+		&SyncMapStore{},
+		&FileStore{Path: filepath.Join(t.TempDir(), "wecom-cache.bin"), Key: make([]byte, 32)},
+	} {
+		s := &TokenSuiteTicketStore{Store: store}
+		ticket, err := s.SuiteTicket("SuiteID")
+		assert.NoError(t, err)
+		assert.Empty(t, ticket)
+
+		assert.NoError(t, s.SetSuiteTicket("SuiteID", "a", now.Unix()-60))
+		assert.NoError(t, s.SetSuiteTicket("SuiteID", "b", now.Unix()))
+		// delayed push
+		assert.NoError(t, s.SetSuiteTicket("SuiteID", "a", now.Unix()-60))
+		ticket, err = s.SuiteTicket("SuiteID")
+		assert.NoError(t, err)
+		assert.Equal(t, "b", ticket)
+
+		ticket, err = s.SuiteTicket("Other")
+		assert.NoError(t, err)
+		assert.Empty(t, ticket)
+
+		assert.Error(t, s.SetSuiteTicket("SuiteID", "", 0))
+	}
+
+	s := &TokenSuiteTicketStore{Store: &SyncMapStore{}}
+	assert.NoError(t, s.SetSuiteTicket("SuiteID", "a", now.Unix()-SuiteTicketExpiresIn))
+	ticket, err := s.SuiteTicket("SuiteID")
+	assert.NoError(t, err)
+	assert.Empty(t, ticket, "expired")
+}
+
+func TestSuiteAccessTokenOfSuiteTicketStore(t *testing.T) {
+	var requested []string
+	mux := chi.NewMux()
+	mux.Post("/cgi-bin/service/get_suite_token", func(w http.ResponseWriter, r *http.Request) {
+		in := ProviderGetSuiteTokenRequest{}
+		assert.NoError(t, render.DecodeJSON(r.Body, &in))
+		requested = append(requested, in.SuiteTicket)
+		if in.SuiteTicket == "expired" {
+			render.JSON(w, r, GenericResponse{ErrorCode: 40085, ErrorMessage: "invalid suite ticket"})
+			return
+		}
+		render.JSON(w, r, SuiteTokenResponse{SuiteAccessToken: "token-" + in.SuiteTicket, ExpiresIn: 7200})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tickets := &TokenSuiteTicketStore{Store: &SyncMapStore{}}
+	client := NewClient(Conf{
+		SuiteID:          "SuiteID",
+		SuiteSecret:      "SuiteSecret",
+		SuiteTicket:      "expired",
+		SuiteTicketStore: tickets,
+	})
+	client.Request.BaseURL = server.URL
+
+	// fallback to Conf.SuiteTicket
+	_, err := client.SuiteAccessToken()
+	assert.Error(t, err)
+
+	// pushed by callback
+	assert.NoError(t, tickets.SetSuiteTicket("SuiteID", "latest", 0))
+	token, err := client.SuiteAccessToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "token-latest", token)
+	assert.Equal(t, []string{"expired", "latest"}, requested)
+}
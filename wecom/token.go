@@ -129,3 +129,24 @@ func (t *Token) expired() bool {
 func (t *Token) Valid() bool {
 	return t != nil && t.AccessToken != "" && !t.expired()
 }
+
+// GetAccessToken implements OpaqueToken
+func (t *Token) GetAccessToken() string {
+	return t.AccessToken
+}
+
+// GetExpiresIn implements OpaqueToken, seconds until Expiry
+func (t *Token) GetExpiresIn() int {
+	if t.Expiry.IsZero() {
+		return 0
+	}
+	return int(t.Expiry.Sub(timeNow()) / time.Second)
+}
+
+// GetExpiresAt unix timestamp of Expiry
+func (t *Token) GetExpiresAt() int64 {
+	if t.Expiry.IsZero() {
+		return 0
+	}
+	return t.Expiry.Unix()
+}
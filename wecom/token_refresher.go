@@ -0,0 +1,205 @@
+package wecom
+
+import (
+	mathrand "math/rand"
+	"sync"
+	"time"
+)
+
+// TokenRefresher TokenProvider which refresh every token it has seen in background when 80% of expires_in is elapsed,
+// the threshold of GenericToken.ShouldRefresh, so requests rarely wait for a token or ticket refresh. The refresh goes
+// through the in-flight load of TokenCache, requests of the same token wait for it instead of fetching again.
+//
+//	refresher := &wecom.TokenRefresher{
+//		Cache:   &wecom.TokenCache{Store: &wecom.SyncMapStore{}},
+//		OnError: func(t *wecom.GenericToken, err error) { log.Println("refresh", t.Type, t.OwnerID, err) },
+//	}
+//	client := wecom.NewClient(wecom.Conf{CorpID: "", CorpSecret: "", TokenProvider: refresher})
+//	refresher.Start()
+//	defer refresher.Stop()
+//
+// Tokens are tracked on the first request, e.g. call client.JsAPITicket once to keep the ticket fresh, and dropped
+// when not requested for MaxIdleRounds or failed by secret or permission errors. Client fetches tokens without the
+// cancellation of Client.WithContext, so the background refresh still works after the tracking request finished.
+// Tokens without expires_in, e.g. SuiteTicket and AuthCorpPermanentCode, are not refreshed.
+type TokenRefresher struct {
+	Cache         *TokenCache                          // required
+	Threshold     float64                              // refresh when this ratio of expires_in is elapsed, default 0.8
+	Interval      time.Duration                        // check interval, default 30s
+	Jitter        time.Duration                        // max random delay added to every interval, default 10s, negative to disable
+	MaxIdleRounds int                                  // stop tracking the token not requested for this many RefreshAll, default 240, negative to disable
+	OnError       func(token *GenericToken, err error) // called when a background refresh failed
+
+	mu      sync.Mutex
+	entries map[string]*refreshEntry
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type refreshEntry struct {
+	key  string
+	exp  GenericToken
+	f    func() (OpaqueToken, error)
+	idle int // RefreshAll rounds since the last request
+}
+
+// Refresh implements TokenProvider, track the token for background refresh
+func (r *TokenRefresher) Refresh(exp *GenericToken, f func() (OpaqueToken, error)) (string, error) {
+	token, err := r.RefreshToken(exp, f)
+	if err != nil {
+		return "", err
+	}
+	return token.Secret, nil
+}
+
+// RefreshToken implements GenericTokenProvider, track the token for background refresh
+func (r *TokenRefresher) RefreshToken(exp *GenericToken, f func() (OpaqueToken, error)) (*GenericToken, error) {
+	if key, err := keyOfGenericToken(exp); err == nil && f != nil {
+		r.mu.Lock()
+		if r.entries == nil {
+			r.entries = make(map[string]*refreshEntry)
+		}
+		// latest f and Depends wins
+		r.entries[key] = &refreshEntry{key: key, exp: *exp, f: f}
+		r.mu.Unlock()
+	}
+	return r.Cache.RefreshToken(exp, f)
+}
+
+// Invalidate implements TokenInvalidator
+func (r *TokenRefresher) Invalidate(exp *GenericToken) error {
+	return r.Cache.Invalidate(exp)
+}
+
+// InvalidatePrefix implements TokenInvalidator
+func (r *TokenRefresher) InvalidatePrefix(typ TokenType, ownerPrefix string) error {
+	return r.Cache.InvalidatePrefix(typ, ownerPrefix)
+}
+
+// Start refresh in background until Stop
+func (r *TokenRefresher) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return
+	}
+	r.stop, r.done = make(chan struct{}), make(chan struct{})
+	go r.run(r.stop, r.done)
+}
+
+// Stop the background refresh and wait for the running refresh
+func (r *TokenRefresher) Stop() {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (r *TokenRefresher) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	for {
+		timer := time.NewTimer(r.delay())
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		r.RefreshAll()
+	}
+}
+
+func (r *TokenRefresher) delay() time.Duration {
+	d, jitter := r.Interval, r.Jitter
+	if d <= 0 {
+		d = 30 * time.Second
+	}
+	if jitter == 0 {
+		jitter = 10 * time.Second
+	}
+	if jitter > 0 {
+		d += time.Duration(mathrand.Int63n(int64(jitter))) //nolint:gosec
+	}
+	return d
+}
+
+// RefreshAll refresh the tracked tokens which reached the Threshold, errors are reported to OnError
+func (r *TokenRefresher) RefreshAll() {
+	maxIdle := r.MaxIdleRounds
+	if maxIdle == 0 {
+		maxIdle = 240
+	}
+	r.mu.Lock()
+	entries := make([]*refreshEntry, 0, len(r.entries))
+	for k, v := range r.entries {
+		v.idle++
+		if maxIdle > 0 && v.idle > maxIdle {
+			delete(r.entries, k)
+			continue
+		}
+		entries = append(entries, v)
+	}
+	r.mu.Unlock()
+
+	for _, e := range entries {
+		err := r.refresh(e)
+		if err == nil {
+			continue
+		}
+		if IsSecretError(err) || IsPermissionDenied(err) {
+			// will not recover by retry, tracked again by the next request
+			r.remove(e)
+		}
+		if r.OnError != nil {
+			exp := e.exp
+			r.OnError(&exp, err)
+		}
+	}
+}
+
+// remove the entry unless it's replaced by a new request
+func (r *TokenRefresher) remove(e *refreshEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.entries[e.key] == e {
+		delete(r.entries, e.key)
+	}
+}
+
+// refresh the token through the in-flight load of Cache, a valid token is kept when the refresh failed
+func (r *TokenRefresher) refresh(e *refreshEntry) error {
+	threshold := r.Threshold
+	if threshold <= 0 || threshold >= 1 {
+		threshold = .8
+	}
+	var ferr error
+	_, err := r.Cache.do(&e.exp, func() (GenericToken, error) {
+		token := e.exp
+		_, err := r.Cache.Store.Load(&token, func(last *GenericToken) (*GenericToken, bool, error) {
+			if last.IsValid() && (last.ExpiresIn == 0 || !last.shouldRefreshAt(&e.exp, threshold)) {
+				return last, false, nil
+			}
+			o, err := e.f()
+			if err != nil && last.IsValid() && last.OwnerID == e.exp.OwnerID && last.Depends == e.exp.Depends {
+				// requests waiting for this refresh keep using the valid one
+				ferr = err
+				return last, false, nil
+			}
+			if err != nil || o == nil {
+				return last, false, err
+			}
+			out := &GenericToken{Type: e.exp.Type, OwnerID: e.exp.OwnerID, Depends: e.exp.Depends}
+			out.SetFromToken(o)
+			return out, true, nil
+		})
+		return token, err
+	})
+	if err != nil {
+		return err
+	}
+	return ferr
+}
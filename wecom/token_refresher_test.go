@@ -0,0 +1,221 @@
+package wecom
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenRefresher(t *testing.T) {
+	n := time.Now()
+	timeNow = func() time.Time {
+		return n
+	}
+	defer func() {
+		timeNow = time.Now
+	}()
+
+	var errs []string
+	r := &TokenRefresher{
+		Cache: &TokenCache{Store: &SyncMapStore{}},
+		OnError: func(token *GenericToken, err error) {
+			errs = append(errs, token.Type+": "+err.Error())
+		},
+	}
+	var calls int
+	var fail bool
+	fetch := func() (OpaqueToken, error) {
+		if fail {
+			return nil, errors.New("failed")
+		}
+		calls++
+		return &GenericToken{Secret: strconv.Itoa(calls), ExpiresIn: 100}, nil
+	}
+	exp := &GenericToken{Type: "A", OwnerID: "corp"}
+	token, err := r.Refresh(exp, fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", token)
+	// not expires
+	_, err = r.Refresh(&GenericToken{Type: "B"}, func() (OpaqueToken, error) {
+		return &GenericToken{Secret: "B"}, nil
+	})
+	assert.NoError(t, err)
+
+	n = n.Add(60 * time.Second)
+	r.RefreshAll()
+	assert.Equal(t, 1, calls)
+
+	// 80% reached
+	n = n.Add(20 * time.Second)
+	r.RefreshAll()
+	assert.Equal(t, 2, calls)
+	token, err = r.Refresh(exp, fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", token)
+	assert.Equal(t, 2, calls)
+
+	n = n.Add(80 * time.Second)
+	fail = true
+	r.RefreshAll()
+	assert.Equal(t, []string{"A: failed"}, errs)
+	// keep using the valid one
+	token, err = r.Refresh(exp, fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", token)
+
+	assert.NoError(t, r.Invalidate(&GenericToken{Type: "A", OwnerID: "corp"}))
+	fail = false
+	token, err = r.Refresh(exp, fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, "3", token)
+}
+
+func TestTokenRefresherSingleFlight(t *testing.T) {
+	n := time.Now()
+	timeNow = func() time.Time {
+		return n
+	}
+	defer func() {
+		timeNow = time.Now
+	}()
+
+	r := &TokenRefresher{Cache: &TokenCache{Store: &SyncMapStore{}}}
+	var calls int32
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	fetch := func() (OpaqueToken, error) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			started <- struct{}{}
+			<-release
+		}
+		return &GenericToken{Secret: strconv.Itoa(int(atomic.LoadInt32(&calls))), ExpiresIn: 100}, nil
+	}
+	exp := &GenericToken{Type: "A"}
+	_, err := r.Refresh(exp, fetch)
+	assert.NoError(t, err)
+
+	// expired, the request wait for the background refresh
+	n = n.Add(200 * time.Second)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.RefreshAll()
+	}()
+	<-started
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	token, err := r.Refresh(exp, fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", token)
+	<-done
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestTokenRefresherEvict(t *testing.T) {
+	r := &TokenRefresher{
+		Cache:         &TokenCache{Store: &SyncMapStore{}},
+		MaxIdleRounds: 2,
+	}
+	fetch := func() (OpaqueToken, error) {
+		return &GenericToken{Secret: "A", ExpiresIn: 7200}, nil
+	}
+	_, err := r.Refresh(&GenericToken{Type: "A"}, fetch)
+	assert.NoError(t, err)
+	r.RefreshAll()
+	r.RefreshAll()
+	_, err = r.Refresh(&GenericToken{Type: "A"}, fetch)
+	assert.NoError(t, err)
+	r.RefreshAll()
+	r.RefreshAll()
+	assert.Len(t, r.entries, 1)
+	r.RefreshAll()
+	assert.Len(t, r.entries, 0)
+
+	// permanent error
+	var calls int
+	_, err = r.Refresh(&GenericToken{Type: "B"}, func() (OpaqueToken, error) {
+		calls++
+		if calls > 1 {
+			return nil, &GenericResponse{ErrorCode: 40001, ErrorMessage: "invalid credential"}
+		}
+		return &GenericToken{Secret: "B", ExpiresIn: 7200}, nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, r.Invalidate(&GenericToken{Type: "B"}))
+	r.RefreshAll()
+	assert.Equal(t, 2, calls)
+	assert.Len(t, r.entries, 0)
+	r.RefreshAll()
+	assert.Equal(t, 2, calls)
+}
+
+func TestTokenRefresherCanceledRequest(t *testing.T) {
+	ts := NewTestServer()
+	defer ts.Start()()
+	n := time.Now()
+	timeNow = func() time.Time {
+		return n
+	}
+	defer func() {
+		timeNow = time.Now
+	}()
+
+	var errs []error
+	r := &TokenRefresher{
+		Cache: &TokenCache{Store: &SyncMapStore{}},
+		OnError: func(token *GenericToken, err error) {
+			errs = append(errs, err)
+		},
+	}
+	client := NewClient(Conf{CorpID: ts.CorpID, CorpSecret: ts.CorpSecret, TokenProvider: r})
+	client.Request.BaseURL = ts.URL()
+
+	// tracked by a request which is finished
+	ctx, cancel := context.WithCancel(context.Background())
+	token, err := client.WithContext(ctx).AccessToken()
+	cancel()
+	assert.NoError(t, err)
+	assert.Equal(t, ts.AccessToken, token)
+
+	ts.ExpireAccessToken()
+	n = n.Add(2 * time.Hour)
+	r.RefreshAll()
+	assert.Empty(t, errs)
+	refreshed, err := r.Cache.Refresh(client.TokenOf(TokenTypeAccessToken), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ts.AccessToken, refreshed)
+	assert.NotEqual(t, token, refreshed)
+}
+
+func TestTokenRefresherStart(t *testing.T) {
+	var calls int32
+	r := &TokenRefresher{
+		Cache:     &TokenCache{Store: &SyncMapStore{}},
+		Threshold: .01,
+		Interval:  10 * time.Millisecond,
+		Jitter:    -1,
+	}
+	_, err := r.Refresh(&GenericToken{Type: "A"}, func() (OpaqueToken, error) {
+		atomic.AddInt32(&calls, 1)
+		return &GenericToken{Secret: "A", ExpiresIn: 1}, nil
+	})
+	assert.NoError(t, err)
+
+	r.Start()
+	r.Start()
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) > 1
+	}, time.Second, 10*time.Millisecond)
+	r.Stop()
+	r.Stop()
+
+	c := atomic.LoadInt32(&calls)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, c, atomic.LoadInt32(&calls))
+}
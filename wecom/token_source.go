@@ -2,7 +2,11 @@ package wecom
 
 import (
 	"context"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 // A TokenSource is anything that can return a token.
@@ -76,3 +80,186 @@ func (s *reuseTokenSource) Token(ctx context.Context) (*Token, error) {
 	s.t = t
 	return t, nil
 }
+
+// AccessTokenSource AccessToken as TokenSource, the token is cached by TokenProvider
+func (c *Client) AccessTokenSource() TokenSource {
+	return c.tokenSource((*Client).accessToken)
+}
+
+// AuthCorpAccessTokenSource AuthCorpAccessToken as TokenSource
+func (c *Client) AuthCorpAccessTokenSource() TokenSource {
+	return c.tokenSource((*Client).authCorpAccessToken)
+}
+
+// ProviderAccessTokenSource ProviderAccessToken as TokenSource
+func (c *Client) ProviderAccessTokenSource() TokenSource {
+	return c.tokenSource((*Client).providerAccessToken)
+}
+
+// SuiteAccessTokenSource SuiteAccessToken as TokenSource
+func (c *Client) SuiteAccessTokenSource() TokenSource {
+	return c.tokenSource((*Client).suiteAccessToken)
+}
+
+func (c *Client) tokenSource(get func(c *Client) (*GenericToken, error)) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		t, err := get(c.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		return tokenOfGeneric(t)
+	})
+}
+
+func tokenOfGeneric(t *GenericToken) (*Token, error) {
+	key, err := keyOfGenericToken(t)
+	if err != nil {
+		return nil, err
+	}
+	out := &Token{TokenKey: key, AccessToken: t.Secret}
+	if t.ExpiresAt > 0 {
+		out.Expiry = time.Unix(t.ExpiresAt, 0)
+	}
+	return out, nil
+}
+
+// TokenSourceProvider TokenProvider which get the tokens from TokenSource instead of wecom, e.g. tokens minted by a central token service.
+// Tokens are reused by Type.OwnerID, Token.TokenKey is checked against the expected Type.OwnerID if present,
+// a source returning tokens without TokenKey serves only the first owner of its type.
+//
+//	client := wecom.NewClient(wecom.Conf{
+//		CorpID: "",
+//		TokenProvider: &wecom.TokenSourceProvider{
+//			Sources: map[wecom.TokenType]wecom.TokenSource{wecom.TokenTypeAccessToken: src},
+//		},
+//	})
+type TokenSourceProvider struct {
+	Sources  map[TokenType]TokenSource // source by token type, reused until the token expires
+	Fallback TokenProvider             // provider of types without source, nil to fail
+	Context  context.Context           // passed to the sources, cancel to stop the slow ones - default context.Background()
+
+	mu     sync.Mutex
+	reused map[string]*reuseTokenSource // by key of the expected token
+	owners map[TokenType]string         // first owner of the source
+}
+
+// source of the expected token, nil if the type has no source
+func (p *TokenSourceProvider) source(exp *GenericToken) (*reuseTokenSource, error) {
+	src, ok := p.Sources[exp.Type]
+	if !ok {
+		return nil, nil
+	}
+	key, err := keyOfGenericToken(exp)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.reused[key]; ok {
+		return s, nil
+	}
+	if p.reused == nil {
+		p.reused = make(map[string]*reuseTokenSource)
+		p.owners = make(map[TokenType]string)
+	}
+	if _, ok := p.owners[exp.Type]; !ok {
+		p.owners[exp.Type] = exp.OwnerID
+	}
+	s := &reuseTokenSource{new: src}
+	p.reused[key] = s
+	return s, nil
+}
+
+func (p *TokenSourceProvider) context() context.Context {
+	if p.Context == nil {
+		return context.Background()
+	}
+	return p.Context
+}
+
+// Refresh implements TokenProvider
+func (p *TokenSourceProvider) Refresh(exp *GenericToken, f func() (OpaqueToken, error)) (string, error) {
+	token, err := p.RefreshToken(exp, f)
+	if err != nil {
+		return "", err
+	}
+	return token.Secret, nil
+}
+
+// RefreshToken implements GenericTokenProvider
+func (p *TokenSourceProvider) RefreshToken(exp *GenericToken, f func() (OpaqueToken, error)) (*GenericToken, error) {
+	src, err := p.source(exp)
+	if err != nil {
+		return nil, err
+	}
+	if src == nil {
+		switch fb := p.Fallback.(type) {
+		case nil:
+			return nil, errors.Errorf("no TokenSource of %v", exp.Type)
+		case GenericTokenProvider:
+			return fb.RefreshToken(exp, f)
+		default:
+			secret, err := fb.Refresh(exp, f)
+			if err != nil {
+				return nil, err
+			}
+			token := *exp
+			token.Secret = secret
+			return &token, nil
+		}
+	}
+	key, _ := keyOfGenericToken(exp)
+	t, err := src.Token(p.context())
+	if err != nil {
+		return nil, err
+	}
+	if t.TokenKey != "" && t.TokenKey != key {
+		return nil, errors.Errorf("TokenSource of %v returned token of %v", key, t.TokenKey)
+	}
+	p.mu.Lock()
+	owner := p.owners[exp.Type]
+	p.mu.Unlock()
+	if t.TokenKey == "" && owner != exp.OwnerID {
+		return nil, errors.Errorf("TokenSource of %v without TokenKey is used by %v", key, owner)
+	}
+	token := &GenericToken{Type: exp.Type, OwnerID: exp.OwnerID, Depends: exp.Depends}
+	token.SetFromToken(t)
+	return token, nil
+}
+
+// Invalidate implements TokenInvalidator, drop the reused token so the next Refresh ask the TokenSource again
+func (p *TokenSourceProvider) Invalidate(exp *GenericToken) error {
+	src, err := p.source(exp)
+	if err != nil || src == nil {
+		if inv, ok := p.Fallback.(TokenInvalidator); ok && err == nil {
+			return inv.Invalidate(exp)
+		}
+		return err
+	}
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	if src.t != nil && (exp.Secret == "" || src.t.AccessToken == exp.Secret) {
+		src.t = nil
+	}
+	return nil
+}
+
+// InvalidatePrefix implements TokenInvalidator
+func (p *TokenSourceProvider) InvalidatePrefix(typ TokenType, ownerPrefix string) error {
+	if _, ok := p.Sources[typ]; !ok {
+		if inv, ok := p.Fallback.(TokenInvalidator); ok {
+			return inv.InvalidatePrefix(typ, ownerPrefix)
+		}
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, src := range p.reused {
+		if owner, ok := ownerOfKey(key, typ); ok && strings.HasPrefix(owner, ownerPrefix) {
+			src.mu.Lock()
+			src.t = nil
+			src.mu.Unlock()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package wecom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenSourceProvider(t *testing.T) {
+	ts := NewTestServer()
+	defer ts.Start()()
+
+	// central token service
+	src := ts.Client.AccessTokenSource()
+	token, err := src.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, ts.AccessToken, token.AccessToken)
+	assert.Equal(t, "AccessToken."+ts.CorpID, token.TokenKey)
+	assert.WithinDuration(t, time.Now().Add(7200*time.Second), token.Expiry, 5*time.Second)
+
+	calls := 0
+	client := NewClient(Conf{
+		CorpID: ts.CorpID,
+		TokenProvider: &TokenSourceProvider{
+			Sources: map[TokenType]TokenSource{
+				TokenTypeAccessToken: TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+					calls++
+					return src.Token(ctx)
+				}),
+			},
+		},
+	})
+	client.Request.BaseURL = ts.URL()
+
+	user, err := client.GetUser(&GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	assert.Equal(t, "zhangsan", user.UserID)
+	_, err = client.GetUser(&GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	// rejected token is dropped, central service refresh it
+	ts.ExpireAccessToken()
+	assert.NoError(t, ts.Client.InvalidateToken(TokenTypeAccessToken))
+	_, err = client.GetUser(&GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	secret, err := client.AccessToken()
+	assert.NoError(t, err)
+	assert.Equal(t, ts.AccessToken, secret)
+
+	// no source
+	_, err = client.ProviderAccessToken()
+	assert.EqualError(t, err, "no TokenSource of ProviderAccessToken")
+
+	// other corp
+	_, err = client.With(Conf{CorpID: "other"}).AccessToken()
+	assert.EqualError(t, err, "TokenSource of AccessToken.other returned token of AccessToken."+ts.CorpID)
+}
+
+func TestTokenSourceProviderOwners(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &TokenSourceProvider{
+		Sources: map[TokenType]TokenSource{
+			TokenTypeAccessToken: TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+				return &Token{AccessToken: "a", Expiry: time.Now().Add(time.Hour)}, nil
+			}),
+			TokenTypeAuthCorpAccessToken: TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				return &Token{TokenKey: "AuthCorpAccessToken.suite.corp1", AccessToken: "corp1", Expiry: time.Now().Add(time.Hour)}, nil
+			}),
+		},
+		Context: ctx,
+	}
+
+	// reused by owner
+	token, err := p.RefreshToken(&GenericToken{Type: TokenTypeAuthCorpAccessToken, OwnerID: "suite.corp1"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "corp1", token.Secret)
+	_, err = p.RefreshToken(&GenericToken{Type: TokenTypeAuthCorpAccessToken, OwnerID: "suite.corp2"}, nil)
+	assert.EqualError(t, err, "TokenSource of AuthCorpAccessToken.suite.corp2 returned token of AuthCorpAccessToken.suite.corp1")
+
+	// without TokenKey, only the first owner is served
+	token, err = p.RefreshToken(&GenericToken{Type: TokenTypeAccessToken, OwnerID: "corp1"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", token.Secret)
+	_, err = p.RefreshToken(&GenericToken{Type: TokenTypeAccessToken, OwnerID: "corp2"}, nil)
+	assert.EqualError(t, err, "TokenSource of AccessToken.corp2 without TokenKey is used by corp1")
+
+	// sources are called with Context
+	assert.NoError(t, p.InvalidatePrefix(TokenTypeAuthCorpAccessToken, "suite."))
+	cancel()
+	_, err = p.RefreshToken(&GenericToken{Type: TokenTypeAuthCorpAccessToken, OwnerID: "suite.corp1"}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,91 @@
+package tokenbroker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fish0607/go-wecom/wecom"
+	"github.com/fish0607/go-wecom/wecom/wecomtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker(t *testing.T) {
+	ts := wecomtest.NewServer()
+	defer ts.Start()()
+
+	upstream := wecom.NewClient(wecom.Conf{
+		CorpID:     ts.CorpID,
+		AgentID:    ts.AgentID,
+		CorpSecret: ts.CorpSecret,
+	})
+	upstream.Request.BaseURL = ts.URL()
+	broker := httptest.NewServer((&Server{Clients: []*wecom.Client{upstream}, Tokens: []string{"token"}}).Handler())
+	defer broker.Close()
+
+	provider := &Provider{URL: broker.URL, Token: "token"}
+	client := wecom.NewClient(wecom.Conf{CorpID: ts.CorpID, TokenProvider: provider})
+	client.Request.BaseURL = ts.URL()
+
+	var errs []error
+	refresher := &wecom.TokenRefresher{
+		Cache:   &wecom.TokenCache{Store: &wecom.SyncMapStore{}},
+		OnError: func(token *wecom.GenericToken, err error) { errs = append(errs, err) },
+	}
+	upstream.TokenProvider = refresher
+
+	user, err := client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	assert.Equal(t, "zhangsan", user.UserID)
+	token, err := client.Token(wecom.TokenTypeAccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, ts.AccessToken, token.Secret)
+	assert.Equal(t, 7200, token.ExpiresIn)
+
+	ticket, err := client.JsAPITicket()
+	assert.NoError(t, err)
+	assert.Equal(t, ts.JsTicket, ticket)
+
+	// rejected token is invalidated on broker
+	old := ts.AccessToken
+	ts.ExpireAccessToken()
+	_, err = client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	secret, err := upstream.AccessToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, old, secret)
+	assert.Equal(t, ts.AccessToken, secret)
+
+	// refreshed after the broker request returned
+	old = ts.AccessToken
+	ts.ExpireAccessToken()
+	assert.NoError(t, refresher.Cache.Invalidate(&wecom.GenericToken{Type: wecom.TokenTypeAccessToken, OwnerID: ts.CorpID, Secret: old}))
+	refresher.RefreshAll()
+	assert.Empty(t, errs)
+	token, err = refresher.Cache.RefreshToken(upstream.TokenOf(wecom.TokenTypeAccessToken), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ts.AccessToken, token.Secret)
+
+	// unknown app
+	_, err = client.With(wecom.Conf{CorpID: "other"}).AccessToken()
+	assert.EqualError(t, err, "-1: no app of AccessToken other")
+	// not supported
+	_, err = client.SuiteAccessToken()
+	assert.Error(t, err)
+
+	// unauthorized
+	client.TokenProvider = &Provider{URL: broker.URL, Token: "invalid"}
+	_, err = client.AccessToken()
+	assert.EqualError(t, err, "-1: unauthorized")
+
+	// broker not responding
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+	client.TokenProvider = &Provider{URL: slow.URL, Token: "token", Timeout: 10 * time.Millisecond}
+	_, err = client.AccessToken()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,112 @@
+package tokenbroker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fish0607/go-wecom/wecom"
+	"github.com/pkg/errors"
+)
+
+// Provider wecom.TokenProvider which get the tokens from the broker, tokens are cached until 80% of expires_in.
+//
+//	client := wecom.NewClient(wecom.Conf{
+//		CorpID:        "ww0000000000000000",
+//		TokenProvider: &tokenbroker.Provider{URL: "http://wecom-token-broker:8080", Token: "token"},
+//	})
+type Provider struct {
+	URL     string        // broker base url
+	Token   string        // bearer token
+	Client  *http.Client  // default http.DefaultClient, requests are limited by Timeout
+	Timeout time.Duration // timeout of a broker request, default 10s
+
+	once  sync.Once
+	cache *wecom.TokenCache
+}
+
+func (p *Provider) tokenCache() *wecom.TokenCache {
+	p.once.Do(func() {
+		p.cache = &wecom.TokenCache{Store: &wecom.SyncMapStore{}}
+	})
+	return p.cache
+}
+
+// Refresh implements wecom.TokenProvider, f is never called
+func (p *Provider) Refresh(exp *wecom.GenericToken, f func() (wecom.OpaqueToken, error)) (string, error) {
+	token, err := p.RefreshToken(exp, f)
+	if err != nil {
+		return "", err
+	}
+	return token.Secret, nil
+}
+
+// RefreshToken implements wecom.GenericTokenProvider
+func (p *Provider) RefreshToken(exp *wecom.GenericToken, _ func() (wecom.OpaqueToken, error)) (*wecom.GenericToken, error) {
+	return p.tokenCache().RefreshToken(exp, func() (wecom.OpaqueToken, error) {
+		out := &wecom.GenericToken{}
+		err := p.post("/token", Request{Type: exp.Type, OwnerID: exp.OwnerID}, out)
+		if err == nil && out.Secret == "" {
+			err = errors.Errorf("tokenbroker: empty token of %v", exp.Type)
+		}
+		return out, err
+	})
+}
+
+// Invalidate implements wecom.TokenInvalidator, drop the cached token and ask the broker to drop the rejected one
+func (p *Provider) Invalidate(exp *wecom.GenericToken) error {
+	if err := p.tokenCache().Invalidate(exp); err != nil {
+		return err
+	}
+	if exp.Secret == "" {
+		return nil
+	}
+	var out wecom.GenericResponse
+	return p.post("/token/invalidate", Request{Type: exp.Type, OwnerID: exp.OwnerID, Secret: exp.Secret}, &out)
+}
+
+// InvalidatePrefix implements wecom.TokenInvalidator, only the cached tokens are dropped, the broker drops the
+// tokens rejected by Invalidate
+func (p *Provider) InvalidatePrefix(typ wecom.TokenType, ownerPrefix string) error {
+	return p.tokenCache().InvalidatePrefix(typ, ownerPrefix)
+}
+
+func (p *Provider) post(path string, in Request, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(p.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.Token)
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "tokenbroker")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		er := wecom.GenericResponse{}
+		if err = json.NewDecoder(res.Body).Decode(&er); err != nil || er.ErrorCode == 0 {
+			return errors.Errorf("tokenbroker: %v %v", path, res.Status)
+		}
+		return er.AsError()
+	}
+	return errors.Wrap(json.NewDecoder(res.Body).Decode(out), "tokenbroker: decode response")
+}
@@ -0,0 +1,140 @@
+// Package tokenbroker serve the tokens of wecom apps over authenticated HTTP, only the broker holds the secrets
+// and calls gettoken, other services use Provider as Conf.TokenProvider.
+//
+//	POST /token            {"Type": "AccessToken", "OwnerID": "ww0000000000000000"}
+//	POST /token/invalidate {"Type": "AccessToken", "OwnerID": "ww0000000000000000", "Secret": "rejected"}
+//
+// Requests are authenticated by Authorization: Bearer <token>, errors are responded as wecom.GenericResponse.
+package tokenbroker
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/fish0607/go-wecom/wecom"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+)
+
+// Server serve tokens of Clients
+type Server struct {
+	Clients []*wecom.Client // apps, match by the Type and OwnerID of TokenOf
+	Tokens  []string        // accepted bearer tokens
+}
+
+// Request of token
+type Request struct {
+	Type    wecom.TokenType
+	OwnerID string `json:",omitempty"`
+	Secret  string `json:",omitempty"` // rejected secret to invalidate
+}
+
+// Handler of the broker api
+func (s *Server) Handler() http.Handler {
+	r := chi.NewRouter()
+	r.Use(s.authenticate)
+	r.Post("/token", s.handleToken)
+	r.Post("/token/invalidate", s.handleInvalidate)
+	return r
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		for _, v := range s.Tokens {
+			if v != "" && subtle.ConstantTimeCompare([]byte(v), []byte(token)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		respondError(w, r, http.StatusUnauthorized, errors.New("unauthorized"))
+	})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	req, client, ok := s.bind(w, r)
+	if !ok {
+		return
+	}
+	// fetched by the app Client, so the token tracked by a TokenRefresher is not bound to this request
+	type result struct {
+		token *wecom.GenericToken
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		token, err := client.Token(req.Type)
+		done <- result{token, err}
+	}()
+	var res result
+	select {
+	case res = <-done:
+	case <-r.Context().Done():
+		respondError(w, r, http.StatusServiceUnavailable, r.Context().Err())
+		return
+	}
+	token, err := res.token, res.err
+	if err != nil {
+		respondError(w, r, http.StatusBadGateway, err)
+		return
+	}
+	render.JSON(w, r, token)
+}
+
+func (s *Server) handleInvalidate(w http.ResponseWriter, r *http.Request) {
+	req, client, ok := s.bind(w, r)
+	if !ok {
+		return
+	}
+	if req.Secret == "" {
+		respondError(w, r, http.StatusBadRequest, errors.New("missing Secret"))
+		return
+	}
+	inv, ok := client.TokenProvider.(wecom.TokenInvalidator)
+	if !ok {
+		respondError(w, r, http.StatusNotImplemented, errors.Errorf("TokenProvider %T can not invalidate token", client.TokenProvider))
+		return
+	}
+	exp := client.TokenOf(req.Type)
+	exp.Secret = req.Secret
+	if err := inv.Invalidate(exp); err != nil {
+		respondError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	render.JSON(w, r, wecom.GenericResponse{ErrorMessage: "ok"})
+}
+
+// bind the request and find the client of the token
+func (s *Server) bind(w http.ResponseWriter, r *http.Request) (req Request, client *wecom.Client, ok bool) {
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		respondError(w, r, http.StatusBadRequest, errors.Wrap(err, "invalid request"))
+		return
+	}
+	switch req.Type {
+	case wecom.TokenTypeAccessToken, wecom.TokenTypeAuthCorpAccessToken, wecom.TokenTypeProviderAccessToken,
+		wecom.TokenTypeSuiteAccessToken, wecom.TokenTypeJsAPITicket, wecom.TokenTypeAgentTicket:
+	default:
+		respondError(w, r, http.StatusBadRequest, errors.Errorf("unsupported token type %q", req.Type))
+		return
+	}
+	for _, c := range s.Clients {
+		if t := c.TokenOf(req.Type); t.Type == req.Type && t.OwnerID == req.OwnerID {
+			return req, c, true
+		}
+	}
+	respondError(w, r, http.StatusNotFound, errors.Errorf("no app of %v %v", req.Type, req.OwnerID))
+	return
+}
+
+// respondError as wecom.GenericResponse, wecom errors keep the errcode
+func respondError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	res := wecom.GenericResponse{ErrorCode: -1, ErrorMessage: err.Error()}
+	var er *wecom.GenericResponse
+	if errors.As(err, &er) {
+		res = *er
+	}
+	render.Status(r, status)
+	render.JSON(w, r, res)
+}
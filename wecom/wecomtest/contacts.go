@@ -0,0 +1,506 @@
+package wecomtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// Contacts in memory state of users, departments and tags, objects are stored as the json of api
+type Contacts struct {
+	mu          sync.Mutex
+	users       map[string]map[string]interface{}
+	departments map[int]map[string]interface{}
+	tags        map[int]*contactTag
+}
+
+type contactTag struct {
+	ID    int
+	Name  string
+	Users []string
+	Party []int
+}
+
+// NewContacts create Contacts with the root department 1
+func NewContacts() *Contacts {
+	return &Contacts{
+		users: map[string]map[string]interface{}{},
+		departments: map[int]map[string]interface{}{
+			1: {"id": 1, "name": "Root", "parentid": 0, "order": 0},
+		},
+		tags: map[int]*contactTag{},
+	}
+}
+
+// User return the stored user, nil if not found
+func (c *Contacts) User(id string) map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.users[id]
+}
+
+// Department return the stored department, nil if not found
+func (c *Contacts) Department(id int) map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.departments[id]
+}
+
+func (c *Contacts) register(mux chi.Router) {
+	mux.Post("/cgi-bin/user/create", c.handle(c.createUser))
+	mux.Get("/cgi-bin/user/get", c.handle(c.getUser))
+	mux.Post("/cgi-bin/user/update", c.handle(c.updateUser))
+	mux.Get("/cgi-bin/user/delete", c.handle(c.deleteUser))
+	mux.Post("/cgi-bin/user/batchdelete", c.handle(c.batchDeleteUser))
+	mux.Get("/cgi-bin/user/simplelist", c.handle(c.listUser(true)))
+	mux.Get("/cgi-bin/user/list", c.handle(c.listUser(false)))
+
+	mux.Post("/cgi-bin/department/create", c.handle(c.createDepartment))
+	mux.Post("/cgi-bin/department/update", c.handle(c.updateDepartment))
+	mux.Get("/cgi-bin/department/delete", c.handle(c.deleteDepartment))
+	mux.Get("/cgi-bin/department/list", c.handle(c.listDepartment(false)))
+	mux.Get("/cgi-bin/department/simplelist", c.handle(c.listDepartment(true)))
+
+	mux.Post("/cgi-bin/tag/create", c.handle(c.createTag))
+	mux.Post("/cgi-bin/tag/update", c.handle(c.updateTag))
+	mux.Get("/cgi-bin/tag/delete", c.handle(c.deleteTag))
+	mux.Get("/cgi-bin/tag/get", c.handle(c.getTag))
+	mux.Post("/cgi-bin/tag/addtagusers", c.handle(c.changeTagUsers(true)))
+	mux.Post("/cgi-bin/tag/deltagusers", c.handle(c.changeTagUsers(false)))
+	mux.Get("/cgi-bin/tag/list", c.handle(c.listTag))
+}
+
+type contactsHandler func(q params) (map[string]interface{}, *Response)
+
+// params of query or json body
+type params map[string]interface{}
+
+func (p params) String(k string) string {
+	switch v := p[k].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	}
+	return ""
+}
+
+func (p params) Int(k string) int {
+	v, _ := strconv.Atoi(p.String(k))
+	return v
+}
+
+func (p params) Strings(k string) (out []string) {
+	a, _ := p[k].([]interface{})
+	for _, v := range a {
+		out = append(out, params{"": v}.String(""))
+	}
+	return
+}
+
+func (p params) Ints(k string) (out []int) {
+	a, _ := p[k].([]interface{})
+	for _, v := range a {
+		out = append(out, params{"": v}.Int(""))
+	}
+	return
+}
+
+func (c *Contacts) handle(h contactsHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := params{}
+		for k, v := range r.URL.Query() {
+			p[k] = v[0]
+		}
+		if r.Method == http.MethodPost {
+			if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+				render.JSON(w, r, Response{ErrorCode: 47001, ErrorMessage: "data format error"})
+				return
+			}
+		}
+		c.mu.Lock()
+		out, er := h(p)
+		c.mu.Unlock()
+		if er != nil {
+			render.JSON(w, r, er)
+			return
+		}
+		if out == nil {
+			out = map[string]interface{}{}
+		}
+		out["errcode"] = 0
+		if _, ok := out["errmsg"]; !ok {
+			out["errmsg"] = "ok"
+		}
+		render.JSON(w, r, out)
+	}
+}
+
+func errorOf(code int, msg string) *Response {
+	return &Response{ErrorCode: code, ErrorMessage: msg}
+}
+
+func (c *Contacts) createUser(p params) (map[string]interface{}, *Response) {
+	id := p.String("userid")
+	switch {
+	case id == "":
+		return nil, errorOf(41009, "missing userid")
+	case p.String("name") == "":
+		return nil, errorOf(40072, "invalid name")
+	case c.users[id] != nil:
+		return nil, errorOf(60102, "userid existed")
+	}
+	for _, v := range p.Ints("department") {
+		if c.departments[v] == nil {
+			return nil, errorOf(60003, "department not found")
+		}
+	}
+	u := map[string]interface{}{"status": 1}
+	merge(u, p)
+	c.users[id] = u
+	return map[string]interface{}{"errmsg": "created"}, nil
+}
+
+func (c *Contacts) getUser(p params) (map[string]interface{}, *Response) {
+	u := c.users[p.String("userid")]
+	if u == nil {
+		return nil, errorOf(60111, "userid not found")
+	}
+	out := map[string]interface{}{}
+	for k, v := range u {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (c *Contacts) updateUser(p params) (map[string]interface{}, *Response) {
+	u := c.users[p.String("userid")]
+	if u == nil {
+		return nil, errorOf(60111, "userid not found")
+	}
+	for _, v := range p.Ints("department") {
+		if c.departments[v] == nil {
+			return nil, errorOf(60003, "department not found")
+		}
+	}
+	merge(u, p)
+	return map[string]interface{}{"errmsg": "updated"}, nil
+}
+
+func (c *Contacts) deleteUser(p params) (map[string]interface{}, *Response) {
+	id := p.String("userid")
+	if c.users[id] == nil {
+		return nil, errorOf(60111, "userid not found")
+	}
+	delete(c.users, id)
+	return map[string]interface{}{"errmsg": "deleted"}, nil
+}
+
+func (c *Contacts) batchDeleteUser(p params) (map[string]interface{}, *Response) {
+	ids := p.Strings("useridlist")
+	for _, id := range ids {
+		if c.users[id] == nil {
+			return nil, errorOf(60111, "userid not found: "+id)
+		}
+	}
+	for _, id := range ids {
+		delete(c.users, id)
+	}
+	return map[string]interface{}{"errmsg": "deleted"}, nil
+}
+
+func (c *Contacts) listUser(simple bool) contactsHandler {
+	return func(p params) (map[string]interface{}, *Response) {
+		dept := p.Int("department_id")
+		if c.departments[dept] == nil {
+			return nil, errorOf(60003, "department not found")
+		}
+		depts := map[int]bool{dept: true}
+		if p.String("fetch_child") == "1" {
+			for _, v := range c.subDepartments(dept) {
+				depts[v] = true
+			}
+		}
+		ids := make([]string, 0, len(c.users))
+		for id := range c.users {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		list := make([]interface{}, 0)
+		for _, id := range ids {
+			u := c.users[id]
+			in := false
+			for _, v := range params(u).Ints("department") {
+				in = in || depts[v]
+			}
+			if !in {
+				continue
+			}
+			if simple {
+				list = append(list, map[string]interface{}{"userid": u["userid"], "name": u["name"], "department": u["department"]})
+			} else {
+				list = append(list, u)
+			}
+		}
+		return map[string]interface{}{"userlist": list}, nil
+	}
+}
+
+// subDepartments recursively, include id
+func (c *Contacts) subDepartments(id int) []int {
+	out := []int{id}
+	for i := 0; i < len(out); i++ {
+		for k, v := range c.departments {
+			if params(v).Int("parentid") == out[i] && k != out[i] {
+				out = append(out, k)
+			}
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+func (c *Contacts) createDepartment(p params) (map[string]interface{}, *Response) {
+	if p.String("name") == "" {
+		return nil, errorOf(60009, "invalid department name")
+	}
+	if c.departments[p.Int("parentid")] == nil {
+		return nil, errorOf(60004, "parent department not found")
+	}
+	id := p.Int("id")
+	if id == 0 {
+		for id = 2; c.departments[id] != nil; id++ {
+		}
+	}
+	if c.departments[id] != nil {
+		return nil, errorOf(60008, "department existed")
+	}
+	d := map[string]interface{}{"order": 0}
+	merge(d, p)
+	d["id"] = id
+	c.departments[id] = d
+	return map[string]interface{}{"errmsg": "created", "id": id}, nil
+}
+
+func (c *Contacts) updateDepartment(p params) (map[string]interface{}, *Response) {
+	d := c.departments[p.Int("id")]
+	if d == nil {
+		return nil, errorOf(60003, "department not found")
+	}
+	if parent := p.Int("parentid"); parent != 0 && c.departments[parent] == nil {
+		return nil, errorOf(60004, "parent department not found")
+	}
+	merge(d, p)
+	return map[string]interface{}{"errmsg": "updated"}, nil
+}
+
+func (c *Contacts) deleteDepartment(p params) (map[string]interface{}, *Response) {
+	id := p.Int("id")
+	switch {
+	case c.departments[id] == nil:
+		return nil, errorOf(60003, "department not found")
+	case id == 1:
+		return nil, errorOf(60123, "can not delete root department")
+	case len(c.subDepartments(id)) > 1:
+		return nil, errorOf(60006, "department contains sub department")
+	}
+	for _, u := range c.users {
+		for _, v := range params(u).Ints("department") {
+			if v == id {
+				return nil, errorOf(60005, "department contains user")
+			}
+		}
+	}
+	delete(c.departments, id)
+	return map[string]interface{}{"errmsg": "deleted"}, nil
+}
+
+func (c *Contacts) listDepartment(simple bool) contactsHandler {
+	return func(p params) (map[string]interface{}, *Response) {
+		var ids []int
+		if id := p.Int("id"); id != 0 {
+			if c.departments[id] == nil {
+				return nil, errorOf(60003, "department not found")
+			}
+			ids = c.subDepartments(id)
+		} else {
+			for k := range c.departments {
+				ids = append(ids, k)
+			}
+			sort.Ints(ids)
+		}
+		list := make([]interface{}, 0, len(ids))
+		for _, id := range ids {
+			d := c.departments[id]
+			if simple {
+				list = append(list, map[string]interface{}{"id": d["id"], "parentid": d["parentid"], "order": d["order"]})
+			} else {
+				list = append(list, d)
+			}
+		}
+		if simple {
+			return map[string]interface{}{"department_id": list}, nil
+		}
+		return map[string]interface{}{"department": list}, nil
+	}
+}
+
+func (c *Contacts) createTag(p params) (map[string]interface{}, *Response) {
+	name := p.String("tagname")
+	if name == "" {
+		return nil, errorOf(40071, "invalid tagname")
+	}
+	for _, v := range c.tags {
+		if v.Name == name {
+			return nil, errorOf(40071, "tagname existed")
+		}
+	}
+	id := p.Int("tagid")
+	if id == 0 {
+		for id = 1; c.tags[id] != nil; id++ {
+		}
+	}
+	if c.tags[id] != nil {
+		return nil, errorOf(40068, "tagid existed")
+	}
+	c.tags[id] = &contactTag{ID: id, Name: name}
+	return map[string]interface{}{"errmsg": "created", "tagid": id}, nil
+}
+
+func (c *Contacts) updateTag(p params) (map[string]interface{}, *Response) {
+	t := c.tags[p.Int("tagid")]
+	if t == nil {
+		return nil, errorOf(40068, "invalid tagid")
+	}
+	if name := p.String("tagname"); name != "" {
+		t.Name = name
+	}
+	return map[string]interface{}{"errmsg": "updated"}, nil
+}
+
+func (c *Contacts) deleteTag(p params) (map[string]interface{}, *Response) {
+	id := p.Int("tagid")
+	if c.tags[id] == nil {
+		return nil, errorOf(40068, "invalid tagid")
+	}
+	delete(c.tags, id)
+	return map[string]interface{}{"errmsg": "deleted"}, nil
+}
+
+func (c *Contacts) getTag(p params) (map[string]interface{}, *Response) {
+	t := c.tags[p.Int("tagid")]
+	if t == nil {
+		return nil, errorOf(40068, "invalid tagid")
+	}
+	users := make([]interface{}, 0, len(t.Users))
+	for _, id := range t.Users {
+		users = append(users, map[string]interface{}{"userid": id, "name": c.users[id]["name"]})
+	}
+	party := t.Party
+	if party == nil {
+		party = []int{}
+	}
+	return map[string]interface{}{"tagname": t.Name, "userlist": users, "partylist": party}, nil
+}
+
+func (c *Contacts) changeTagUsers(add bool) contactsHandler {
+	return func(p params) (map[string]interface{}, *Response) {
+		t := c.tags[p.Int("tagid")]
+		if t == nil {
+			return nil, errorOf(40068, "invalid tagid")
+		}
+		users, party := p.Strings("userlist"), p.Ints("partylist")
+		if len(users) == 0 && len(party) == 0 {
+			return nil, errorOf(40031, "userlist and partylist are empty")
+		}
+		for _, v := range users {
+			if c.users[v] == nil {
+				return nil, errorOf(60111, "userid not found: "+v)
+			}
+		}
+		for _, v := range party {
+			if c.departments[v] == nil {
+				return nil, errorOf(60003, "department not found")
+			}
+		}
+		if add {
+			t.Users = appendMissing(t.Users, users...)
+			t.Party = appendMissing(t.Party, party...)
+		} else {
+			t.Users = removeAll(t.Users, users...)
+			t.Party = removeAll(t.Party, party...)
+		}
+		return map[string]interface{}{"errmsg": "ok"}, nil
+	}
+}
+
+func (c *Contacts) listTag(p params) (map[string]interface{}, *Response) {
+	ids := make([]int, 0, len(c.tags))
+	for k := range c.tags {
+		ids = append(ids, k)
+	}
+	sort.Ints(ids)
+	list := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, map[string]interface{}{"tagid": id, "tagname": c.tags[id].Name})
+	}
+	return map[string]interface{}{"taglist": list}, nil
+}
+
+// merge update fields, zero value means unchanged
+func merge(dst map[string]interface{}, src params) {
+	for k, v := range src {
+		switch v := v.(type) {
+		case nil:
+			continue
+		case string:
+			if v == "" {
+				continue
+			}
+		case float64:
+			if v == 0 {
+				continue
+			}
+		case []interface{}:
+			if len(v) == 0 {
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+func appendMissing[T comparable](s []T, v ...T) []T {
+	for _, e := range v {
+		if !contains(s, e) {
+			s = append(s, e)
+		}
+	}
+	return s
+}
+
+func removeAll[T comparable](s []T, v ...T) []T {
+	out := s[:0]
+	for _, e := range s {
+		if !contains(v, e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func contains[T comparable](s []T, v T) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,346 @@
+package wecomtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wenerme/go-req"
+)
+
+// RecorderMode of Recorder
+type RecorderMode int
+
+const (
+	// ModeReplay respond by recorded fixtures, unmatched request fails
+	ModeReplay RecorderMode = iota
+	// ModeRecord send request to wecom and save the scrubbed fixtures
+	ModeRecord
+)
+
+// Redacted replace scrubbed string value
+const Redacted = "REDACTED"
+
+// DefaultScrubKeys secrets, tokens and personal fields scrubbed from query, request and response
+var DefaultScrubKeys = []string{
+	"access_token", "suite_access_token", "provider_access_token",
+	"corpsecret", "provider_secret", "suite_secret", "secret",
+	"suite_ticket", "ticket", "permanent_code", "pre_auth_code", "auth_code", "code", "key",
+	"mobile", "telephone", "email", "biz_mail", "address", "avatar", "thumb_avatar", "qr_code", "unionid",
+}
+
+// Recorder record request/response pairs of Client.Request as fixtures, replay them in test
+//
+//	rec := &wecomtest.Recorder{Dir: "testdata", Mode: wecomtest.ModeRecord}
+//	client.Request = client.Request.WithHook(rec.Hook())
+//
+// Fixtures are saved as <Dir>/cgi-bin/<path>.request.json and <Dir>/cgi-bin/<path>.response.json for the first call
+// of a path, the n-th call is saved as <path>.<n>.request.json and <path>.<n>.response.json. Replay match the calls
+// of a path in the same order, the n-th call fails if it's not recorded or the request is different.
+//
+// The Hook has Order -1, go-req wraps it outside the transport of the Client - token retry, RateLimiter, Backoff and
+// Observer. Record saves the final response after them, replay returns the fixture without running them, e.g. a
+// recorded 42001 is returned as is. The access_token is added to the query before the transport.
+type Recorder struct {
+	Dir       string            // fixture dir, contains cgi-bin
+	FS        fs.FS             // replay from FS instead of Dir, e.g. Fixtures
+	Mode      RecorderMode      // default ModeReplay
+	ScrubKeys []string          // default DefaultScrubKeys
+	Next      http.RoundTripper // transport to record, default http.DefaultTransport
+	T         interface {
+		Helper()
+		Errorf(format string, args ...interface{})
+	} // report unmatched request, e.g. *testing.T
+
+	mu    sync.Mutex
+	calls map[string]int // calls of path
+}
+
+// Hook use Recorder as transport of req.Request
+func (rec *Recorder) Hook() req.Hook {
+	return req.Hook{
+		Name:  "WecomRecorder",
+		Order: -1,
+		HandleRequest: func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				return rec.roundTrip(next, r)
+			})
+		},
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (rec *Recorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	next := rec.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return rec.roundTrip(next, r)
+}
+
+// fixtureName of the n-th call of the request path, without the suffix
+func (rec *Recorder) fixtureName(r *http.Request) (string, int) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.calls == nil {
+		rec.calls = make(map[string]int)
+	}
+	rec.calls[name]++
+	n := rec.calls[name]
+	if n > 1 {
+		name += "." + strconv.Itoa(n)
+	}
+	return name, n
+}
+
+func (rec *Recorder) roundTrip(next http.RoundTripper, r *http.Request) (*http.Response, error) {
+	if rec.Mode == ModeRecord {
+		return rec.record(next, r)
+	}
+	res, err := rec.replay(r)
+	if err != nil && rec.T != nil {
+		rec.T.Helper()
+		rec.T.Errorf("%v", err)
+	}
+	return res, err
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func (rec *Recorder) scrubKeys() map[string]bool {
+	keys := rec.ScrubKeys
+	if keys == nil {
+		keys = DefaultScrubKeys
+	}
+	m := make(map[string]bool, len(keys))
+	for _, v := range keys {
+		m[v] = true
+	}
+	return m
+}
+
+// requestOf json body or query of request, tokens in query are excluded
+func (rec *Recorder) requestOf(r *http.Request, body []byte) (interface{}, error) {
+	if len(bytes.TrimSpace(body)) > 0 {
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	v := map[string]interface{}{}
+	for k, vv := range r.URL.Query() {
+		if isTokenKey(k) {
+			continue
+		}
+		v[k] = vv[0]
+	}
+	if len(v) == 0 {
+		return nil, nil
+	}
+	return v, nil
+}
+
+func isTokenKey(k string) bool {
+	switch k {
+	case "access_token", "suite_access_token", "provider_access_token", "debug":
+		return true
+	}
+	return false
+}
+
+func (rec *Recorder) record(next http.RoundTripper, r *http.Request) (*http.Response, error) {
+	body, err := readBody(&r.Body)
+	if err != nil {
+		return nil, err
+	}
+	res, err := next.RoundTrip(r)
+	if err != nil {
+		return res, err
+	}
+	resBody, err := readBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := rec.scrubKeys()
+	name, _ := rec.fixtureName(r)
+	if in, err := rec.requestOf(r, body); err != nil {
+		return nil, fmt.Errorf("wecomtest: record %v: %w", r.URL.Path, err)
+	} else if in != nil {
+		if err = rec.write(name+".request.json", scrub(in, keys)); err != nil {
+			return nil, err
+		}
+	}
+	var out interface{}
+	if err = json.Unmarshal(resBody, &out); err != nil {
+		// not json, e.g. media
+		return res, nil
+	}
+	return res, rec.write(name+".response.json", scrub(out, keys))
+}
+
+func (rec *Recorder) write(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fn := filepath.Join(rec.Dir, filepath.FromSlash(name))
+	if err = os.MkdirAll(filepath.Dir(fn), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(fn, append(data, '\n'), 0o644) //nolint:gosec
+}
+
+func (rec *Recorder) replay(r *http.Request) (*http.Response, error) {
+	f := rec.FS
+	if f == nil {
+		f = os.DirFS(rec.Dir)
+	}
+	name, n := rec.fixtureName(r)
+	data, err := fs.ReadFile(f, name+".response.json")
+	if err != nil {
+		return nil, fmt.Errorf("wecomtest: unmatched request %v %v: no recorded response of call %v", r.Method, r.URL.Path, n)
+	}
+
+	body, err := readBody(&r.Body)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := rec.requestOf(r, body)
+	if err != nil {
+		return nil, fmt.Errorf("wecomtest: unmatched request %v %v: %w", r.Method, r.URL.Path, err)
+	}
+	var expected interface{}
+	if d, err := fs.ReadFile(f, name+".request.json"); err == nil {
+		if err = json.Unmarshal(d, &expected); err != nil {
+			return nil, fmt.Errorf("wecomtest: invalid recorded request %v: %w", r.URL.Path, err)
+		}
+	}
+	keys := rec.scrubKeys()
+	if !equalRecorded(scrub(expected, keys), scrub(actual, keys)) {
+		a, _ := json.Marshal(scrub(actual, keys))
+		return nil, fmt.Errorf("wecomtest: unmatched request %v %v of call %v: %s", r.Method, r.URL.Path, n, a)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json; charset=UTF-8"}},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       r,
+	}, nil
+}
+
+// equalRecorded compare scrubbed json, query string is equal to number of same text
+func equalRecorded(expected, actual interface{}) bool {
+	if reflect.DeepEqual(expected, actual) {
+		return true
+	}
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for k, v := range e {
+			if !equalRecorded(v, a[k]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for i, v := range e {
+			if !equalRecorded(v, a[i]) {
+				return false
+			}
+		}
+		return true
+	case nil:
+		return false
+	}
+	return fmt.Sprint(expected) == fmt.Sprint(actual)
+}
+
+// scrub replace value of keys, keep the json type
+func scrub(v interface{}, keys map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			if keys[k] {
+				out[k] = scrubValue(e)
+			} else {
+				out[k] = scrub(e, keys)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = scrub(e, keys)
+		}
+		return out
+	}
+	return v
+}
+
+func scrubValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return v
+		}
+		return Redacted
+	case float64:
+		return 0
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = scrubValue(e)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = scrubValue(e)
+		}
+		return out
+	}
+	return v
+}
+
+// readBody read the body and keep it readable
+func readBody(rc *io.ReadCloser) ([]byte, error) {
+	if *rc == nil || *rc == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*rc)
+	if err != nil {
+		return nil, err
+	}
+	_ = (*rc).Close()
+	*rc = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
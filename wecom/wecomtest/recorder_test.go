@@ -0,0 +1,81 @@
+package wecomtest_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fish0607/go-wecom/wecom"
+	"github.com/fish0607/go-wecom/wecom/wecomtest"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeT struct {
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	ts := wecomtest.NewServer()
+	ts.Contacts = wecomtest.NewContacts()
+	closer := ts.Start()
+
+	{
+		client := newClient(ts)
+		client.Request = client.Request.WithHook((&wecomtest.Recorder{Dir: dir, Mode: wecomtest.ModeRecord}).Hook())
+		_, err := client.CreateUser(&wecom.CreateUserRequest{UserID: "zhangsan", Name: "张三", Mobile: "13800000000", Department: []int{1}})
+		assert.NoError(t, err)
+		user, err := client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+		assert.NoError(t, err)
+		assert.Equal(t, "13800000000", user.Mobile)
+		_, err = client.UpdateUser(&wecom.UpdateUserRequest{UserID: "zhangsan", Name: "张三丰"})
+		assert.NoError(t, err)
+		_, err = client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+		assert.NoError(t, err)
+	}
+	closer()
+
+	for _, fn := range []string{"gettoken.request.json", "gettoken.response.json", "user/create.request.json", "user/get.request.json", "user/get.response.json", "user/get.2.response.json"} {
+		data, err := os.ReadFile(filepath.Join(dir, "cgi-bin", fn))
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), ts.CorpSecret, fn)
+		assert.NotContains(t, string(data), ts.AccessToken, fn)
+		assert.NotContains(t, string(data), "13800000000", fn)
+	}
+
+	// replay without server, calls of a path in order
+	ft := &fakeT{}
+	client := newClient(ts)
+	client.Request = client.Request.WithHook((&wecomtest.Recorder{Dir: dir, T: ft}).Hook())
+	user, err := client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	assert.Equal(t, "张三", user.Name)
+	assert.Equal(t, wecomtest.Redacted, user.Mobile)
+	_, err = client.CreateUser(&wecom.CreateUserRequest{UserID: "zhangsan", Name: "张三", Mobile: "13900000000", Department: []int{1}})
+	assert.NoError(t, err)
+	_, err = client.UpdateUser(&wecom.UpdateUserRequest{UserID: "zhangsan", Name: "张三丰"})
+	assert.NoError(t, err)
+	user, err = client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	assert.Equal(t, "张三丰", user.Name)
+	assert.Empty(t, ft.errors)
+
+	_, err = client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+	assert.ErrorContains(t, err, "wecomtest: unmatched request GET /cgi-bin/user/get: no recorded response of call 3")
+	_, err = client.DeleteUser(&wecom.DeleteUserRequest{UserID: "zhangsan"})
+	assert.ErrorContains(t, err, "wecomtest: unmatched request GET /cgi-bin/user/delete: no recorded response of call 1")
+	assert.Len(t, ft.errors, 2)
+
+	client = newClient(ts)
+	client.Request = client.Request.WithHook((&wecomtest.Recorder{Dir: dir, T: ft}).Hook())
+	_, err = client.GetUser(&wecom.GetUserRequest{UserID: "lisi"})
+	assert.ErrorContains(t, err, `wecomtest: unmatched request GET /cgi-bin/user/get of call 1: {"userid":"lisi"}`)
+	assert.Len(t, ft.errors, 3)
+}
@@ -0,0 +1,387 @@
+// Package wecomtest provide a mock wecom api server for testing, serve the api fixtures in testdata.
+//
+//	ts := wecomtest.NewServer()
+//	defer ts.Start()()
+//	client := wecom.NewClient(wecom.Conf{
+//		CorpID:     ts.CorpID,
+//		AgentID:    ts.AgentID,
+//		CorpSecret: ts.CorpSecret,
+//	})
+//	client.Request.BaseURL = ts.URL()
+package wecomtest
+
+import (
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+//go:embed testdata/cgi-bin
+var fixtures embed.FS
+
+// Fixtures of api, named as cgi-bin/<path>.request.json and cgi-bin/<path>.response.json
+var Fixtures fs.FS
+
+func init() {
+	var err error
+	Fixtures, err = fs.Sub(fixtures, "testdata")
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Server mock wecom api server
+//
+// Server issue tokens by gettoken and service/get_provider_token, validate the tokens in query,
+// validate json request body against the request fixture, then respond by Contacts or the response fixture.
+type Server struct {
+	CorpID              string
+	AgentID             int
+	CorpSecret          string
+	AccessToken         string
+	JsTicket            string
+	AgentTicket         string
+	ProviderSecret      string
+	ProviderAccessToken string
+
+	Fixtures fs.FS     // default Fixtures
+	Strict   bool      // reject request field not in request fixture
+	Contacts *Contacts // serve user, department and tag api by state instead of fixtures, nil to disable
+	Mux      *chi.Mux  // add or override api handlers
+	Server   *httptest.Server
+
+	mu             sync.Mutex
+	expired        map[string]bool
+	providerIssued bool
+	errors         []*injectedError
+}
+
+type injectedError struct {
+	Path     string
+	Times    int
+	Response Response
+}
+
+// Response of wecom api
+type Response struct {
+	ErrorCode    int    `json:"errcode"`
+	ErrorMessage string `json:"errmsg"`
+}
+
+// NewServer create a Server with random corp, secret and tokens
+func NewServer() *Server {
+	return &Server{
+		CorpID:              "CorpID" + createNonce(),
+		AgentID:             1000000 + randInt(1000000),
+		CorpSecret:          "CorpSecret" + createNonce(),
+		AccessToken:         "AccessToken" + createNonce(),
+		JsTicket:            "JsTicket" + createNonce(),
+		AgentTicket:         "AgentTicket" + createNonce(),
+		ProviderSecret:      "ProviderSecret" + createNonce(),
+		ProviderAccessToken: "ProviderToken" + createNonce(),
+		Fixtures:            Fixtures,
+		Mux:                 chi.NewMux(),
+	}
+}
+
+// Start serve by httptest.Server, return the close func
+func (s *Server) Start() func() {
+	s.Server = httptest.NewServer(s.Handler())
+	return s.Server.Close
+}
+
+// URL of started server, used as BaseURL of Client
+func (s *Server) URL() string {
+	if s.Server == nil {
+		return ""
+	}
+	return s.Server.URL
+}
+
+// Handler register api handlers to Mux and return the http.Handler of Server
+func (s *Server) Handler() http.Handler {
+	mux := s.Mux
+	mux.Get("/cgi-bin/gettoken", s.handleGetToken)
+	mux.Get("/cgi-bin/get_jsapi_ticket", func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, map[string]interface{}{"errcode": 0, "errmsg": "ok", "ticket": s.JsTicket, "expires_in": 7200})
+	})
+	mux.Get("/cgi-bin/ticket/get", func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, map[string]interface{}{"errcode": 0, "errmsg": "ok", "ticket": s.AgentTicket, "expires_in": 7200})
+	})
+	mux.Post("/cgi-bin/service/get_provider_token", s.handleGetProviderToken)
+	if s.Contacts != nil {
+		s.Contacts.register(mux)
+	}
+	mux.HandleFunc("/cgi-bin/*", s.handleFixture)
+	return http.HandlerFunc(s.serveHTTP)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if res, ok := s.takeError(r.URL.Path); ok {
+		render.JSON(w, r, res)
+		return
+	}
+	if res, ok := s.checkToken(r); !ok {
+		render.JSON(w, r, res)
+		return
+	}
+	if res, ok := s.checkRequest(r); !ok {
+		render.JSON(w, r, res)
+		return
+	}
+	s.Mux.ServeHTTP(w, r)
+}
+
+// InjectError respond errcode for next n requests of path, n <= 0 for always, path * match all api
+func (s *Server) InjectError(path string, n int, code int, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = append(s.errors, &injectedError{Path: path, Times: n, Response: Response{ErrorCode: code, ErrorMessage: msg}})
+}
+
+// ClearErrors remove all injected errors
+func (s *Server) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = nil
+}
+
+func (s *Server) takeError(path string) (Response, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, v := range s.errors {
+		if v.Path != path && v.Path != "*" {
+			continue
+		}
+		if v.Times > 0 {
+			v.Times--
+			if v.Times == 0 {
+				s.errors = append(s.errors[:i], s.errors[i+1:]...)
+			}
+		}
+		return v.Response, true
+	}
+	return Response{}, false
+}
+
+// ExpireAccessToken issue a new access_token, the old one will be rejected by 42001
+func (s *Server) ExpireAccessToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.expired == nil {
+		s.expired = map[string]bool{}
+	}
+	s.expired[s.AccessToken] = true
+	s.AccessToken = "AccessToken" + createNonce()
+}
+
+func (s *Server) handleGetToken(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	switch {
+	case q.Get("corpid") != s.CorpID:
+		render.JSON(w, r, Response{ErrorCode: 40013, ErrorMessage: "invalid corpid"})
+	case q.Get("corpsecret") != s.CorpSecret:
+		render.JSON(w, r, Response{ErrorCode: 40001, ErrorMessage: "invalid credential"})
+	default:
+		s.mu.Lock()
+		token := s.AccessToken
+		s.mu.Unlock()
+		render.JSON(w, r, map[string]interface{}{"errcode": 0, "errmsg": "ok", "access_token": token, "expires_in": 7200})
+	}
+}
+
+func (s *Server) handleGetProviderToken(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		CorpID         string `json:"corpid"`
+		ProviderSecret string `json:"provider_secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		render.JSON(w, r, Response{ErrorCode: 47001, ErrorMessage: "data format error"})
+		return
+	}
+	if in.CorpID != s.CorpID || in.ProviderSecret != s.ProviderSecret {
+		render.JSON(w, r, Response{ErrorCode: 40001, ErrorMessage: "invalid credential"})
+		return
+	}
+	s.mu.Lock()
+	s.providerIssued = true
+	s.mu.Unlock()
+	render.JSON(w, r, map[string]interface{}{"errcode": 0, "errmsg": "ok", "provider_access_token": s.ProviderAccessToken, "expires_in": 7200})
+}
+
+// checkToken validate access_token and provider_access_token in query,
+// provider_access_token is validated after issued, service api fixtures contain their own token.
+func (s *Server) checkToken(r *http.Request) (Response, bool) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q.Has("access_token") {
+		switch token := q.Get("access_token"); {
+		case token == s.AccessToken:
+		case s.expired[token]:
+			return Response{ErrorCode: 42001, ErrorMessage: "access_token expired"}, false
+		default:
+			return Response{ErrorCode: 40014, ErrorMessage: "invalid access_token"}, false
+		}
+	}
+	if s.providerIssued && q.Has("provider_access_token") && q.Get("provider_access_token") != s.ProviderAccessToken {
+		return Response{ErrorCode: 40014, ErrorMessage: "invalid provider_access_token"}, false
+	}
+	return Response{}, true
+}
+
+func (s *Server) fixture(path string, typ string) ([]byte, error) {
+	f := s.Fixtures
+	if f == nil {
+		f = Fixtures
+	}
+	return fs.ReadFile(f, strings.TrimPrefix(path, "/")+"."+typ+".json")
+}
+
+// checkRequest validate json body against the request fixture, fields in both must have the same json type
+func (s *Server) checkRequest(r *http.Request) (Response, bool) {
+	if r.Body == nil || r.Method == http.MethodGet {
+		return Response{}, true
+	}
+	data, err := s.fixture(r.URL.Path, "request")
+	if err != nil {
+		return Response{}, true
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return Response{ErrorCode: -1, ErrorMessage: err.Error()}, false
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(strings.NewReader(string(body)))
+	if len(body) == 0 {
+		return Response{}, true
+	}
+
+	var expected, actual interface{}
+	if err = json.Unmarshal(data, &expected); err != nil {
+		return Response{ErrorCode: -1, ErrorMessage: "invalid request fixture: " + err.Error()}, false
+	}
+	if err = json.Unmarshal(body, &actual); err != nil {
+		return Response{ErrorCode: 47001, ErrorMessage: "data format error: " + err.Error()}, false
+	}
+	if err = matchJSON("", expected, actual, s.Strict); err != nil {
+		return Response{ErrorCode: 40058, ErrorMessage: err.Error()}, false
+	}
+	return Response{}, true
+}
+
+func matchJSON(name string, expected, actual interface{}, strict bool) error {
+	if expected == nil || actual == nil {
+		return nil
+	}
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid field %q: expected object", name)
+		}
+		for k, v := range a {
+			ev, found := e[k]
+			if !found {
+				if strict {
+					return fmt.Errorf("unknown field %q", joinName(name, k))
+				}
+				continue
+			}
+			if err := matchJSON(joinName(name, k), ev, v, strict); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid field %q: expected array", name)
+		}
+		if len(e) == 0 {
+			return nil
+		}
+		for i, v := range a {
+			if err := matchJSON(fmt.Sprintf("%v[%v]", name, i), e[0], v, strict); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	ek, ak := jsonKind(expected), jsonKind(actual)
+	switch {
+	case ek == ak:
+	// wecom accepts number as string and numeric string as number
+	case ek == "string" && ak == "number":
+	case ek == "number" && ak == "string" && isNumeric(actual.(string)):
+	default:
+		return fmt.Errorf("invalid field %q: expected %v got %v", name, ek, ak)
+	}
+	return nil
+}
+
+func isNumeric(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "null"
+}
+
+func joinName(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "." + b
+}
+
+func (s *Server) handleFixture(w http.ResponseWriter, r *http.Request) {
+	data, err := s.fixture(r.URL.Path, "response")
+	if err != nil {
+		// request only api
+		if _, err := s.fixture(r.URL.Path, "request"); err == nil {
+			render.JSON(w, r, Response{ErrorMessage: "ok"})
+			return
+		}
+		render.JSON(w, r, Response{ErrorCode: -1, ErrorMessage: err.Error()})
+		return
+	}
+	render.JSON(w, r, json.RawMessage(data))
+}
+
+func createNonce() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func randInt(n int64) int {
+	v, _ := rand.Int(rand.Reader, big.NewInt(n))
+	return int(v.Int64())
+}
@@ -0,0 +1,130 @@
+package wecomtest_test
+
+import (
+	"testing"
+
+	"github.com/fish0607/go-wecom/wecom"
+	"github.com/fish0607/go-wecom/wecom/wecomtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/wenerme/go-req"
+)
+
+func newClient(ts *wecomtest.Server) *wecom.Client {
+	client := wecom.NewClient(wecom.Conf{
+		CorpID:     ts.CorpID,
+		AgentID:    ts.AgentID,
+		CorpSecret: ts.CorpSecret,
+		Backoff:    &wecom.Backoff{},
+	})
+	client.Request.BaseURL = ts.URL()
+	return client
+}
+
+func TestServer(t *testing.T) {
+	ts := wecomtest.NewServer()
+	defer ts.Start()()
+	client := newClient(ts)
+
+	// fixture
+	user, err := client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	assert.Equal(t, "zhangsan", user.UserID)
+
+	token, err := client.AccessToken()
+	assert.NoError(t, err)
+	assert.Equal(t, ts.AccessToken, token)
+
+	// refresh expired token
+	ts.ExpireAccessToken()
+	_, err = client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	token, err = client.AccessToken()
+	assert.NoError(t, err)
+	assert.Equal(t, ts.AccessToken, token)
+
+	ts.InjectError("/cgi-bin/user/get", 1, 45033, "api concurrent out of limit")
+	_, err = client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+	assert.True(t, wecom.IsRateLimited(err))
+	_, err = client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+
+	ts.InjectError("*", 0, 60020, "not allow to access from your ip")
+	_, err = client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+	assert.True(t, wecom.IsPermissionDenied(err))
+	ts.ClearErrors()
+
+	// request validation
+	_, err = client.GetExternalContact(&wecom.GetExternalContactRequest{ExternalUserID: "woAJ2GCAAAXtWyujaWJHDDGi0mACAAA"})
+	assert.NoError(t, err)
+	_, err = client.BatchDeleteUser(&wecom.BatchDeleteUserRequest{UserIDList: []string{"test"}})
+	assert.NoError(t, err)
+	var out wecom.GenericResponse
+	err = client.Request.With(req.Request{
+		Method: "POST",
+		URL:    "/cgi-bin/user/batchdelete",
+		Body:   map[string]interface{}{"useridlist": "test"},
+	}).Fetch(&out)
+	assert.EqualError(t, err, `40058: invalid field "useridlist": expected array`)
+
+	ts.Strict = true
+	err = client.Request.With(req.Request{
+		Method: "POST",
+		URL:    "/cgi-bin/user/batchdelete",
+		Body:   map[string]interface{}{"useridlist": []string{"test"}, "unknown": 1},
+	}).Fetch(&out)
+	assert.EqualError(t, err, `40058: unknown field "unknown"`)
+}
+
+func TestContacts(t *testing.T) {
+	ts := wecomtest.NewServer()
+	ts.Contacts = wecomtest.NewContacts()
+	defer ts.Start()()
+	client := newClient(ts)
+
+	dept, err := client.CreateDepartment(&wecom.CreateDepartmentRequest{Name: "研发", ParentID: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, dept.ID)
+	_, err = client.CreateDepartment(&wecom.CreateDepartmentRequest{Name: "研发", ParentID: 10})
+	assert.EqualError(t, err, "60004: parent department not found")
+
+	_, err = client.CreateUser(&wecom.CreateUserRequest{UserID: "zhangsan", Name: "张三", Department: []int{2}})
+	assert.NoError(t, err)
+	_, err = client.CreateUser(&wecom.CreateUserRequest{UserID: "zhangsan", Name: "张三", Department: []int{2}})
+	assert.True(t, errors.Is(err, &wecom.GenericResponse{ErrorCode: 60102}))
+	_, err = client.UpdateUser(&wecom.UpdateUserRequest{UserID: "zhangsan", Position: "工程师"})
+	assert.NoError(t, err)
+
+	user, err := client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	assert.Equal(t, "张三", user.Name)
+	assert.Equal(t, "工程师", user.Position)
+	assert.Equal(t, []int{2}, user.Department)
+	assert.Equal(t, "工程师", ts.Contacts.User("zhangsan")["position"])
+
+	list, err := client.SimpleListUser(&wecom.SimpleListUserRequest{DepartmentID: "1", FetchChild: "1"})
+	assert.NoError(t, err)
+	assert.Len(t, list.UserList, 1)
+	list, err = client.SimpleListUser(&wecom.SimpleListUserRequest{DepartmentID: "1"})
+	assert.NoError(t, err)
+	assert.Len(t, list.UserList, 0)
+
+	tag, err := client.CreateTag(&wecom.CreateTagRequest{TagName: "UI"})
+	assert.NoError(t, err)
+	_, err = client.AddTagUsers(&wecom.AddTagUsersRequest{TagID: tag.TagIDInt(), UserList: []string{"zhangsan"}})
+	assert.NoError(t, err)
+	tags, err := client.GetTag(&wecom.GetTagRequest{TagID: tag.TagID})
+	assert.NoError(t, err)
+	assert.Equal(t, "UI", tags.TagName)
+	assert.Len(t, tags.UserList, 1)
+
+	_, err = client.DeleteDepartment(&wecom.DeleteDepartmentRequest{ID: "2"})
+	assert.EqualError(t, err, "60005: department contains user")
+	_, err = client.DeleteUser(&wecom.DeleteUserRequest{UserID: "zhangsan"})
+	assert.NoError(t, err)
+	_, err = client.GetUser(&wecom.GetUserRequest{UserID: "zhangsan"})
+	assert.True(t, wecom.IsNotFound(err))
+	_, err = client.DeleteDepartment(&wecom.DeleteDepartmentRequest{ID: "2"})
+	assert.NoError(t, err)
+	assert.Nil(t, ts.Contacts.Department(2))
+}